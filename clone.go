@@ -0,0 +1,98 @@
+package merge
+
+import "reflect"
+
+// deepCopy returns a deep copy of v. Pointers, maps and slices are copied
+// recursively; the copies map tracks those already copied, so shared and
+// cyclic references are preserved in the copy. Unexported struct fields
+// are copied shallowly.
+func deepCopy(v reflect.Value, copies map[visit]reflect.Value) reflect.Value {
+	return copyValue(v, copies, false)
+}
+
+// copyOf returns a deep copy of v for merging into instead of v. With WithUnexportedFields
+// the unexported fields of the structs that merge writes to are copied deeply too.
+func (c *Config) copyOf(v reflect.Value) reflect.Value {
+	return copyValue(v, make(map[visit]reflect.Value), c.unexportedFields && canExposeUnexported)
+}
+
+// copyValue is deepCopy, copying unexported fields deeply if unexported is true.
+func copyValue(v reflect.Value, copies map[visit]reflect.Value, unexported bool) reflect.Value {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		k := visit{valuePointer(v), v.Type()}
+		if p, ok := copies[k]; ok {
+			return p
+		}
+
+		p := reflect.New(v.Type().Elem())
+		cp := p.Convert(v.Type())
+		copies[k] = cp
+		p.Elem().Set(copyValue(v.Elem(), copies, unexported))
+		return cp
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		k := visit{valuePointer(v), v.Type()}
+		if m, ok := copies[k]; ok {
+			return m
+		}
+
+		m := reflect.MakeMapWithSize(v.Type(), v.Len())
+		copies[k] = m
+		for it := v.MapRange(); it.Next(); {
+			m.SetMapIndex(it.Key(), copyValue(it.Value(), copies, unexported))
+		}
+		return m
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		k := visit{valuePointer(v), v.Type()}
+		if s, ok := copies[k]; ok && s.Len() == v.Len() {
+			return s
+		}
+
+		s := reflect.MakeSlice(v.Type(), v.Len(), v.Cap())
+		copies[k] = s
+		for i := 0; i < v.Len(); i++ {
+			s.Index(i).Set(copyValue(v.Index(i), copies, unexported))
+		}
+		return s
+	case reflect.Array:
+		a := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			a.Index(i).Set(copyValue(v.Index(i), copies, unexported))
+		}
+		return a
+	case reflect.Struct:
+		s := reflect.New(v.Type()).Elem()
+		s.Set(v)
+		expose := unexported && cachedFields(v.Type()).mergeable
+		for i, n := 0, s.NumField(); i < n; i++ {
+			f := s.Field(i)
+			if !f.CanSet() {
+				if !expose {
+					continue
+				}
+				// Copy what s, a shallow copy of v, holds.
+				f = exposeUnexported(f)
+			}
+			f.Set(copyValue(f, copies, unexported))
+		}
+		return s
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		i := reflect.New(v.Type()).Elem()
+		i.Set(copyValue(v.Elem(), copies, unexported))
+		return i
+	default:
+		return v
+	}
+}
@@ -7,18 +7,64 @@
 package merge
 
 import (
+	"encoding"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
+	"math/cmplx"
 	"reflect"
+	"strconv"
+	"strings"
+	"time"
 	"unicode"
 	"unicode/utf8"
-	"unsafe"
 )
 
+var (
+	binaryUnmarshalerType = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+	timeType              = reflect.TypeOf(time.Time{})
+	jsonNumberType        = reflect.TypeOf(json.Number(""))
+)
+
+// parseJSONNumber returns n as an int64 or a uint64 value if it is an integer
+// in their range, and as the nearest float64 value otherwise.
+func parseJSONNumber(n json.Number) (reflect.Value, error) {
+	if i, err := n.Int64(); err == nil {
+		return reflect.ValueOf(i), nil
+	}
+	if u, err := strconv.ParseUint(n.String(), 10, 64); err == nil {
+		return reflect.ValueOf(u), nil
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return reflect.Value{}, fmt.Errorf("%q cannot be represented as a number", n)
+	}
+	return reflect.ValueOf(f), nil
+}
+
+// unixTime returns the local Time corresponding to n units since January 1, 1970 UTC.
+// It reports false if the Time is outside the years 0 through 9999.
+func unixTime(n int64, unit time.Duration) (time.Time, bool) {
+	var t time.Time
+	switch unit {
+	case time.Second:
+		t = time.Unix(n, 0)
+	case time.Millisecond:
+		t = time.UnixMilli(n)
+	case time.Microsecond:
+		t = time.UnixMicro(n)
+	default:
+		t = time.Unix(0, n)
+	}
+	y := t.UTC().Year()
+	return t, 0 <= y && y <= 9999
+}
+
 // Maps for deep map using reflected types. The map argument tracks
 // comparisons that have already been seen, which allows short circuiting on
 // recursive types.
-func deepValueMap(path string, dst, src reflect.Value, visited map[visit]string, c *Config) error {
+func deepValueMap(path string, dst, src reflect.Value, visited map[visit]string, c *Config) (err error) {
 	// debugf("deepValueMap %q\n", path)
 
 	if !dst.IsValid() || !src.IsValid() {
@@ -28,6 +74,27 @@ func deepValueMap(path string, dst, src reflect.Value, visited map[visit]string,
 		return errors.New("v1.IsValid() != v2.IsValid()")
 	}
 
+	c.enter(path)
+	if len(c.scopes) > 0 {
+		if c, err = c.at(path); err != nil {
+			return err
+		}
+	}
+
+	if c.beforeHook != nil {
+		c.beforeHook(path, dst, src)
+	}
+	if c.afterHook != nil {
+		defer c.afterHook(path, dst, src)
+	}
+	if fn := c.postProcessors[dst.Type()]; fn != nil {
+		defer func() {
+			if err == nil {
+				err = fn(dst)
+			}
+		}()
+	}
+
 	// if dst.Type() != src.Type() {
 	// 	return errors.New(dst.Type().String() + " != " + src.Type().String())
 	// }
@@ -47,17 +114,19 @@ func deepValueMap(path string, dst, src reflect.Value, visited map[visit]string,
 		return false
 	}
 
-	if hard(src) {
+	// A concrete src is passed on as is to the value that an interface dst holds,
+	// and visited there.
+	if hard(src) && (reflect.Interface != dst.Kind() || reflect.Interface == src.Kind()) {
 		// For a Pointer or Map value, we need to check flagIndir,
 		// which we do by calling the pointer method.
 		// For Slice or Interface, flagIndir is always set,
 		// and using v.ptr suffices.
-		ptrval := func(v reflect.Value) unsafe.Pointer {
+		ptrval := func(v reflect.Value) pointer {
 			switch v.Kind() {
 			case reflect.Pointer, reflect.Map, reflect.Slice:
-				return v.UnsafePointer()
+				return valuePointer(v)
 			default:
-				return v.Addr().UnsafePointer()
+				return valuePointer(v.Addr())
 			}
 		}
 
@@ -77,9 +146,80 @@ func deepValueMap(path string, dst, src reflect.Value, visited map[visit]string,
 		visited[v] = stack()
 	}
 
-	if fn := c.transformers[dst.Type()]; fn.IsValid() {
-		if err, _ := fn.Call([]reflect.Value{dst.Addr(), src})[0].Interface().(error); err != nil {
-			return err
+	if ok, err := c.transform(path, dst, src); ok {
+		return err
+	}
+	if ok, err := c.transformPartial(dst, src); ok {
+		return err
+	}
+	if isOrderedMap(dst, src) {
+		return c.mergeOrderedMap(path, dst, src, visited, deepValueMap)
+	}
+
+	// Slices of bytes deeply map to encoding.BinaryUnmarshaler values by decoding.
+	if reflect.Slice == src.Kind() && reflect.Uint8 == src.Type().Elem().Kind() &&
+		dst.CanAddr() && dst.Addr().Type().Implements(binaryUnmarshalerType) {
+		if (dst.IsZero() || c.overwrite) && (src.Len() > 0 || c.overwriteWithEmptyValue) {
+			debugf("%q (%s, %#v) <- (%s, %q)\n", path, dst.Type(), dst, src.Type(), src)
+			return dst.Addr().Interface().(encoding.BinaryUnmarshaler).UnmarshalBinary(src.Bytes())
+		}
+		return nil
+	}
+
+	// JSON numbers deeply map to numbers by parsing, as decoded by json.Decoder.UseNumber.
+	if jsonNumberType == src.Type() && isNumeric(dst.Kind()) {
+		n, err := parseJSONNumber(json.Number(src.String()))
+		if err != nil {
+			return fmt.Errorf("%q: %w", path, err)
+		}
+		src = n
+	}
+
+	// Integers deeply map to time.Time as Unix timestamps.
+	if c.unixTimeUnit != 0 && timeType == dst.Type() && (src.CanInt() || src.CanUint()) {
+		var n int64
+		if src.CanInt() {
+			n = src.Int()
+		} else {
+			if src.Uint() > math.MaxInt64 {
+				return fmt.Errorf("%q: %d out of range for Unix time", path, src.Uint())
+			}
+			n = int64(src.Uint())
+		}
+
+		t, ok := unixTime(n, c.unixTimeUnit)
+		if !ok {
+			return fmt.Errorf("%q: %d out of range for Unix time in %s", path, n, c.unixTimeUnit)
+		}
+		if (dst.IsZero() || c.overwrite) && (n != 0 || c.overwriteWithEmptyValue) {
+			debugf("%q (%s, %#v) <- (%s, %#v)\n", path, dst.Type(), dst, src.Type(), src)
+			dst.Set(reflect.ValueOf(t))
+		}
+		return nil
+	}
+
+	// Strings deeply map to registered enum types by name.
+	if enum := c.enums[dst.Type()]; enum != nil && reflect.String == src.Kind() {
+		i, ok := enum[src.String()]
+		if !ok {
+			return fmt.Errorf("%q is not a valid %s", src.String(), dst.Type())
+		}
+
+		var overflow bool
+		if dst.CanInt() {
+			overflow = dst.OverflowInt(i)
+		} else {
+			overflow = i < 0 || dst.OverflowUint(uint64(i))
+		}
+		if overflow {
+			return fmt.Errorf("%d overflow %s", i, dst.Type())
+		}
+
+		v := reflect.ValueOf(i).Convert(dst.Type())
+
+		if (dst.IsZero() || c.overwrite) && (!v.IsZero() || c.overwriteWithEmptyValue) {
+			debugf("%q (%s, %#v) <- (%s, %q)\n", path, dst.Type(), dst, src.Type(), src)
+			dst.Set(v)
 		}
 		return nil
 	}
@@ -115,6 +255,19 @@ func deepValueMap(path string, dst, src reflect.Value, visited map[visit]string,
 			return errors.New("src must have kind Slice or Array")
 		}
 
+		// Strings deeply map to byte slices as a whole, like byte slices to strings.
+		if reflect.String == src.Kind() && src.Type().ConvertibleTo(dst.Type()) && !c.concatSlices() {
+			if (dst.Len() == 0 || c.overwrite) && (src.Len() > 0 || c.overwriteWithEmptyValue) {
+				if c.typeCheck && c.overwrite {
+					return fmt.Errorf("overwrite two different types %s <- %s", dst.Type(), src.Type())
+				}
+
+				debugf("%q (%s, %q) <- (%s, %q)\n", path, dst.Type(), dst, src.Type(), src)
+				dst.Set(src.Convert(dst.Type()))
+			}
+			return nil
+		}
+
 		if dst.Len() == 0 && (src.Len() == 0 && c.overwriteEmptySlice) {
 			if reflect.Slice == src.Kind() {
 				if dst.IsNil() != src.IsNil() {
@@ -124,7 +277,7 @@ func deepValueMap(path string, dst, src reflect.Value, visited map[visit]string,
 						if src.IsNil() {
 							dst.Set(reflect.Zero(dst.Type()))
 						} else {
-							dst.Set(reflect.MakeSlice(dst.Type(), 0, 0))
+							dst.Set(c.makeSlice(dst.Type(), 0, 0))
 						}
 					}
 				}
@@ -132,7 +285,7 @@ func deepValueMap(path string, dst, src reflect.Value, visited map[visit]string,
 			return nil
 		}
 
-		if c.appendSlice {
+		if c.concatSlices() {
 			var ss reflect.Value
 			sk := src.Kind()
 			switch sk {
@@ -164,33 +317,66 @@ func deepValueMap(path string, dst, src reflect.Value, visited map[visit]string,
 				}
 			}
 
-			dst.Set(reflect.AppendSlice(dst, ss))
+			switch {
+			case c.prependSlice:
+				dst.Set(c.prepend(dst, ss))
+			case SliceAppendUnique == c.sliceStrategy:
+				dst.Set(c.appendUnique(dst, ss))
+			default:
+				dst.Set(c.appendSlice(dst, ss))
+			}
 			return nil
 		}
+		if SliceReplace == c.sliceStrategy {
+			if src.Len() == 0 && !c.overwriteWithEmptyValue {
+				return nil
+			}
+			// See deepValueMerge.
+			dst.Set(reflect.Zero(dst.Type()))
+		}
 
 		if dst.Len() < src.Len() {
-			if src.Len() <= dst.Cap() {
-				dst.Set(dst.Slice(0, src.Len()))
-			} else {
-				s := reflect.MakeSlice(dst.Type(), src.Len(), src.Len())
-				reflect.Copy(s, dst)
-				dst.Set(s)
-			}
+			c.growSlice(dst, src.Len())
 		}
 
-		if dst.UnsafePointer() == src.UnsafePointer() {
+		if valuePointer(dst) == valuePointer(src) {
 			return nil
 		}
 
 		for i := 0; i < dst.Len() && i < src.Len(); i++ {
-			if err := deepValueMap(fmt.Sprintf("%s[%d]", path, i),
-				dst.Index(i), src.Index(i), visited, c); err != nil {
+			elemPath := fmt.Sprintf("%s[%d]", path, i)
+			dv, sv := dst.Index(i), src.Index(i)
+
+			if ok, err := c.transformElement(elemPath, dst.Type(), dv, sv); ok {
+				if err != nil {
+					return err
+				}
+				continue
+			}
+
+			// A nil dst pointer gets a copy of the value src points to,
+			// even with WithoutDereference, so that dst and src do not alias.
+			if reflect.Pointer == dv.Kind() && reflect.Pointer == sv.Kind() && dv.IsNil() && !sv.IsNil() {
+				p := c.new(dv.Type().Elem())
+				if err := deepValueMap("(*"+elemPath+")", p.Elem(), sv.Elem(), visited, c); err != nil {
+					return err
+				}
+				dv.Set(p)
+				continue
+			}
+
+			if err := deepValueMap(elemPath, dv, sv, visited, c); err != nil {
 				return err
 			}
 		}
 
+		if SliceTruncate == c.sliceStrategy && dst.Len() > src.Len() {
+			dst.Set(dst.Slice(0, src.Len()))
+			return nil
+		}
+
 		// Ensure that all elements in dst are zeroed if src's len shorter than dst.
-		if c.overwriteWithEmptyValue {
+		if c.overwriteWithEmptyValue && !c.preferLongerSlice {
 			for i := src.Len(); i < dst.Len(); i++ {
 				dst.Index(i).SetZero()
 			}
@@ -199,14 +385,21 @@ func deepValueMap(path string, dst, src reflect.Value, visited map[visit]string,
 		return nil
 	case reflect.Interface:
 		if c.shouldNotDereference {
-			if (dst.IsNil() || c.overwrite) &&
-				(reflect.Interface == src.Kind() && (!src.IsNil() || c.overwriteWithEmptyValue)) {
+			// src may be another interface type, such as a map value of type any,
+			// or a concrete value; dst is set to the concrete value it holds.
+			se := src
+			if reflect.Interface == se.Kind() && !se.IsNil() {
+				se = se.Elem()
+			}
+			if (dst.IsNil() || c.overwrite) && (reflect.Interface != se.Kind() || c.overwriteWithEmptyValue) {
 				dt := dst.Type()
-				st := src.Type()
-				if dt == st {
-					dst.Set(src)
-				} else if st.ConvertibleTo(dt) {
-					dst.Set(src.Convert(dt))
+				switch {
+				case reflect.Interface == se.Kind():
+					dst.SetZero()
+				case se.Type().AssignableTo(dt):
+					dst.Set(se)
+				case se.Type().ConvertibleTo(dt):
+					dst.Set(se.Convert(dt))
 				}
 			}
 			return nil
@@ -236,20 +429,27 @@ func deepValueMap(path string, dst, src reflect.Value, visited map[visit]string,
 
 		var de reflect.Value
 		if dst.IsNil() {
-			de = reflect.New(se.Type()).Elem()
+			de = c.new(se.Type()).Elem()
 		} else {
-			de = reflect.New(dst.Elem().Type()).Elem()
+			de = c.new(dst.Elem().Type()).Elem()
 			de.Set(dst.Elem())
 		}
 
-		if de.Kind() != se.Kind() {
-			if c.overwrite && !c.appendSlice {
+		if !dst.IsNil() && de.Type() != se.Type() && c.typeCheck && c.overwrite {
+			return fmt.Errorf("%q: overwrite interface value with difference concrete type %s <- %s", path, de.Type(), se.Type())
+		}
+
+		// Convertible values keep the concrete type of dst; numbers are converted
+		// by deepValueMap, which checks that they are representable.
+		if !dst.IsNil() && de.Kind() != se.Kind() && c.interfaceConvert && se.Type().ConvertibleTo(de.Type()) {
+			if !isNumeric(de.Kind()) || !isNumeric(se.Kind()) {
+				se = se.Convert(de.Type())
+			}
+		} else if de.Kind() != se.Kind() {
+			if c.overwrite && !c.concatSlices() {
 				if !se.Type().Implements(dst.Type()) {
 					return errors.New("overwrite src type not implements dst interface type")
 				}
-				if de.Type() != se.Type() && c.typeCheck {
-					return errors.New("overwrite interface value with difference concrete type")
-				}
 
 				dst.Set(se)
 			}
@@ -262,7 +462,14 @@ func deepValueMap(path string, dst, src reflect.Value, visited map[visit]string,
 		dst.Set(de)
 		return nil
 	case reflect.Pointer:
-		if c.shouldNotDereference {
+		// With WithFillNilPointers only nil dst pointers are mapped into.
+		if c.fillNilPointers && !dst.IsNil() {
+			return nil
+		}
+		if c.shouldNotDereference && !c.fillNilPointers {
+			if ok, err := c.transformPointee(path, dst, src); ok {
+				return err
+			}
 			if (dst.IsNil() || c.overwrite) &&
 				(reflect.Pointer == src.Kind() && (!src.IsNil() || c.overwriteWithEmptyValue)) {
 				dt := dst.Type()
@@ -275,7 +482,7 @@ func deepValueMap(path string, dst, src reflect.Value, visited map[visit]string,
 			}
 			return nil
 		}
-		if dst.UnsafePointer() == src.UnsafePointer() {
+		if valuePointer(dst) == valuePointer(src) {
 			return nil
 		}
 
@@ -288,7 +495,7 @@ func deepValueMap(path string, dst, src reflect.Value, visited map[visit]string,
 				return nil
 			}
 			if dst.IsNil() {
-				dst.Set(reflect.New(dst.Type().Elem()))
+				dst.Set(c.new(dst.Type().Elem()))
 			}
 		}
 
@@ -311,40 +518,76 @@ func deepValueMap(path string, dst, src reflect.Value, visited map[visit]string,
 		default:
 			return fmt.Errorf("%s cannot be represents %s", dst.Kind().String(), src.Kind().String())
 		case reflect.Map:
+			fields := cachedFields(dst.Type())
+
 			var hasExportedField bool
-			for i, n := 0, dst.NumField(); i < n; i++ {
-				typeOfF := dst.Type().Field(i)
+			for i, typeOfF := range fields.list {
 				if !typeOfF.IsExported() {
 					continue
 				}
 
 				hasExportedField = true
 
-				fieldName := typeOfF.Name
-				k := reflect.ValueOf(fieldName)
-				se := src.MapIndex(k)
+				se := mapIndexByFieldName(src, typeOfF.Name)
 				if !se.IsValid() {
-					r, size := utf8.DecodeRuneInString(fieldName)
-					fieldName = string(unicode.ToLower(r)) + fieldName[size:]
-					k = reflect.ValueOf(fieldName)
-					se = src.MapIndex(k)
+					continue
+				}
+
+				se = reflect.ValueOf(se.Interface())
+
+				fieldPath := path + typeOfF.mapPath
+				if !c.filterField(fieldPath, typeOfF.StructField) {
+					continue
+				}
+				if typeOfF.omitEmpty && (!se.IsValid() || c.isZero(se)) {
+					continue
+				}
+
+				df := dst.Field(i)
+				if reflect.Pointer == df.Kind() {
+					if df.IsNil() {
+						df.Set(c.new(df.Type().Elem()))
+					}
+					df, fieldPath = df.Elem(), "(*"+fieldPath+")"
+				}
+				if err := deepValueMap(fieldPath, df, se, visited, c.forMapValue(src, se)); err != nil {
+					return err
 				}
+			}
+
+			// Map keys may also name fields promoted from embedded structs.
+			// Following Go's selector rules, a key maps to the shallowest
+			// field of that name only, so outer fields shadow embedded ones.
+			for _, typeOfF := range fields.promoted {
+				// The embedding field was mapped as a whole.
+				if mapIndexByFieldName(src, fields.list[typeOfF.Index[0]].Name).IsValid() {
+					continue
+				}
+
+				se := mapIndexByFieldName(src, typeOfF.Name)
 				if !se.IsValid() {
 					continue
 				}
 
 				se = reflect.ValueOf(se.Interface())
 
-				fieldPath := fmt.Sprintf("%s[%s]", path, typeOfF.Name)
+				hasExportedField = true
+				fieldPath := path + typeOfF.mapPath
+				if !c.filterField(fieldPath, typeOfF.StructField) {
+					continue
+				}
 
-				df := dst.Field(i)
+				df, ok := c.fieldByIndexAlloc(dst, typeOfF.Index)
+				if !ok {
+					continue
+				}
 				if reflect.Pointer == df.Kind() {
 					if df.IsNil() {
-						df.Set(reflect.New(df.Type().Elem()))
+						df.Set(c.new(df.Type().Elem()))
 					}
-					df = df.Elem()
+					df, fieldPath = df.Elem(), "(*"+fieldPath+")"
 				}
-				if err := deepValueMap(fieldPath, df, se, visited, c); err != nil {
+				if err := deepValueMap(fieldPath, df, se, visited, c.forMapValue(src, se)); err != nil {
 					return err
 				}
 			}
@@ -355,16 +598,40 @@ func deepValueMap(path string, dst, src reflect.Value, visited map[visit]string,
 				return nil
 			}
 		case reflect.Struct:
+			if c.fieldAliases != nil && dst.Type() != src.Type() {
+				return c.mergeStructByName(path, dst, src, visited, deepValueMap, mapFieldPath)
+			}
+
 			var hasExportedField bool
-			for i := 0; i < dst.NumField() && i < src.NumField(); i++ {
-				typeOfF := dst.Type().Field(i)
-				if !typeOfF.IsExported() && reflect.Struct != typeOfF.Type.Kind() && !typeOfF.Anonymous {
+			fs := cachedFields(dst.Type())
+			var expose bool
+			src, expose = c.exposeFields(fs, dst, src)
+			fields := fs.list
+			for i := 0; i < len(fields) && i < src.NumField(); i++ {
+				typeOfF := &fields[i]
+				df, sf := dst.Field(i), src.Field(i)
+				if expose && !typeOfF.IsExported() {
+					df, sf = exposeUnexported(df), exposeUnexported(sf)
+				} else if !typeOfF.mergeable {
 					continue
 				}
 
 				hasExportedField = true
-				fieldPath := fmt.Sprintf("%s[%s]", path, typeOfF.Name)
-				if err := deepValueMap(fieldPath, dst.Field(i), src.Field(i), visited, c); err != nil {
+				fieldPath := path + typeOfF.mapPath
+				if !c.filterField(fieldPath, typeOfF.StructField) {
+					continue
+				}
+				// See deepValueMerge.
+				if typeOfF.omitEmpty && c.isZero(sf) {
+					continue
+				}
+				if ok, err := c.transformField(fieldPath, typeOfF, df, sf); ok {
+					if err != nil {
+						return err
+					}
+					continue
+				}
+				if err := deepValueMap(fieldPath, df, sf, visited, c); err != nil {
 					return err
 				}
 			}
@@ -372,33 +639,43 @@ func deepValueMap(path string, dst, src reflect.Value, visited map[visit]string,
 			if hasExportedField {
 				return nil
 			}
+
+			// See deepValueMerge.
+			if !dst.CanSet() {
+				if c.strictUnexported {
+					return fmt.Errorf("%q: cannot map %s with only unexported fields into an unexported field", path, dst.Type())
+				}
+				return nil
+			}
 		}
 	case reflect.Map:
 		switch src.Kind() {
 		default:
 			return fmt.Errorf("%s cannot be represents %s", dst.Kind().String(), src.Kind().String())
 		case reflect.Struct:
-			for i, n := 0, src.NumField(); i < n; i++ {
-				typeOfF := src.Type().Field(i)
+			kt := dst.Type().Key()
+			if reflect.String != kt.Kind() {
+				return fmt.Errorf("%s keys cannot be represents field names", kt.String())
+			}
+
+			for i, typeOfF := range cachedFields(src.Type()).list {
 				if !typeOfF.IsExported() {
 					continue
 				}
 
-				fieldName := typeOfF.Name
-				k := reflect.ValueOf(fieldName)
+				k := reflect.ValueOf(typeOfF.Name).Convert(kt)
 				de := dst.MapIndex(k)
-				if !de.IsValid() {
-					r, size := utf8.DecodeRuneInString(fieldName)
-					fieldName = string(unicode.ToLower(r)) + fieldName[size:]
-					k = reflect.ValueOf(fieldName)
+				if !de.IsValid() && c.mapKeyStyle != KeyStyleExact {
+					k = reflect.ValueOf(typeOfF.keys[c.mapKeyStyle]).Convert(kt)
 					de = dst.MapIndex(k)
 				}
+				old := de
 
 				if !de.IsValid() {
-					de = reflect.New(src.Field(i).Type()).Elem()
+					de = c.new(src.Field(i).Type()).Elem()
 				} else {
 					de = reflect.ValueOf(de.Interface())
-					elm := reflect.New(de.Type()).Elem()
+					elm := c.new(de.Type()).Elem()
 					elm.Set(de)
 					de = elm
 				}
@@ -407,64 +684,114 @@ func deepValueMap(path string, dst, src reflect.Value, visited map[visit]string,
 					de, src.Field(i), visited, c); err != nil {
 					return err
 				}
-				dst.SetMapIndex(k, de)
+				c.setMapIndex(dst, k, de, old)
 			}
 			return nil
+		case reflect.Slice, reflect.Array:
+			if c.sliceKeyField == "" {
+				return fmt.Errorf("%s cannot be represents %s", dst.Kind().String(), src.Kind().String())
+			}
+			return c.mapSliceByKey(path, dst, src, visited)
 		case reflect.Map:
 		}
 
+		// WithKeyTransformer checks the keys it returns itself.
+		if kt, skt := dst.Type().Key(), src.Type().Key(); c.keyTransformer == nil && !skt.AssignableTo(kt) {
+			return fmt.Errorf("%q: %s keys cannot be used as %s keys", path, skt.String(), kt.String())
+		}
+
 		if dst.IsNil() != src.IsNil() {
-			if dst.IsNil() && src.Len() > 0 {
-				dst.Set(reflect.MakeMapWithSize(dst.Type(), src.Len()))
+			if dst.IsNil() && (src.Len() > 0 || c.initEmptyMaps) {
+				dst.Set(c.makeMap(dst.Type(), src.Len()))
 			}
 		}
-		if dst.UnsafePointer() == src.UnsafePointer() {
+		if valuePointer(dst) == valuePointer(src) {
 			return nil
 		}
-		for it := src.MapRange(); it.Next(); {
-			k := it.Key()
+		if err := c.normalizeMapKeys(path, dst, visited, deepValueMap); err != nil {
+			return err
+		}
+		for it := c.mapRange(src); it.Next(); {
+			k, err := c.transformKey(path, dst, it.Key())
+			if err != nil {
+				return err
+			}
 			val1 := it.Value()
-			val2 := dst.MapIndex(k)
+			old := dst.MapIndex(k)
+			val2 := old
 
+			// Values of a map[string]any map by their dynamic type
+			// into maps of other value types, e.g. map[string]int.
+			if et := dst.Type().Elem(); reflect.Interface == val1.Kind() && reflect.Interface != et.Kind() {
+				val1 = val1.Elem()
+			}
 			if !val1.IsValid() {
 				continue
 			}
 
+			if ok, err := c.resolveMapConflict(fmt.Sprintf("%s[%v]", path, k), dst, k, old, val1); ok {
+				if err != nil {
+					return err
+				}
+				continue
+			}
+
+			if v, ok := c.appendMapSlice(old, val1); ok {
+				c.setMapValue(fmt.Sprintf("%s[%v]", path, k), dst, k, v, old)
+				continue
+			}
+
+			// A new or nil dst pointer gets a copy of the value src points to,
+			// even with WithoutDereference, so that dst and src do not alias.
+			if et := dst.Type().Elem(); reflect.Pointer == et.Kind() && reflect.Pointer == val1.Kind() &&
+				!val1.IsNil() && (!old.IsValid() || old.IsNil()) {
+				p := c.new(et.Elem())
+				if err := deepValueMap(fmt.Sprintf("(*%s[%v])", path, k), p.Elem(), val1.Elem(), visited, c); err != nil {
+					return err
+				}
+				c.setMapIndex(dst, k, p.Convert(et), old)
+				continue
+			}
+
 			if !val2.IsValid() {
-				v := reflect.New(val1.Type()).Elem()
-				v.SetZero()
-				val2 = v
+				val2 = c.new(dst.Type().Elem()).Elem()
 				debugf("add map key (%#v, %#v)\n", k, val1)
 			} else {
-				v := reflect.New(val2.Type()).Elem()
+				v := c.new(val2.Type()).Elem()
 				v.Set(val2)
 				val2 = v
 			}
 
-			if err := deepValueMap(fmt.Sprintf("%s[%s]", path,
-				k.String()), val2, val1, visited, c); err != nil {
+			if err := deepValueMap(fmt.Sprintf("%s[%v]", path, k),
+				val2, val1, visited, c.forMapValue(src, val1)); err != nil {
 				return err
 			}
-			dst.SetMapIndex(k, val2)
+			c.setMapIndex(dst, k, val2, old)
 		}
 
 		// Ensure that all keys in dst are deleted if they are not present in src.
-		if c.overwriteWithEmptyValue {
-			for it := dst.MapRange(); it.Next(); {
+		if c.pruneMissingKeys {
+			for it := c.mapRange(dst); it.Next(); {
 				k := it.Key()
-				if !src.MapIndex(k).IsValid() {
+				if !c.srcHasKey(src, k) {
 					dst.SetMapIndex(k, reflect.Value{})
 				}
 			}
 		}
 		return nil
 	case reflect.String:
+		// Arrays of bytes and runes map like slices of them.
+		if reflect.Array == src.Kind() && isBytesOrRunes(reflect.SliceOf(src.Type().Elem())) {
+			src = sliceOfArray(src)
+		}
+
 		switch src.Kind() {
 		default:
 			return fmt.Errorf("%s can not represents %s", dst.Kind().String(), src.Kind().String())
 		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-			if src.Int() != int64(int32(src.Int())) {
-				return fmt.Errorf("%d cannot be represented as an int32", src.Int())
+			// Integers map to strings as runes, like string(rune(i)).
+			if src.Int() < 0 || src.Int() > utf8.MaxRune || !utf8.ValidRune(rune(src.Int())) {
+				return fmt.Errorf("%q: %d is not a valid Unicode code point", path, src.Int())
 			}
 
 			r := reflect.ValueOf(int32(src.Int()))
@@ -481,8 +808,8 @@ func deepValueMap(path string, dst, src reflect.Value, visited map[visit]string,
 			}
 			return nil
 		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
-			if src.Uint() != uint64(int32(src.Uint())) {
-				return fmt.Errorf("%d cannot be represented as an int32", src.Uint())
+			if src.Uint() > utf8.MaxRune || !utf8.ValidRune(rune(src.Uint())) {
+				return fmt.Errorf("%q: %d is not a valid Unicode code point", path, src.Uint())
 			}
 
 			r := reflect.ValueOf(int32(src.Uint()))
@@ -501,8 +828,7 @@ func deepValueMap(path string, dst, src reflect.Value, visited map[visit]string,
 		case reflect.Slice:
 			switch src.Type().Elem().Kind() {
 			case reflect.Uint8:
-				bs := reflect.ValueOf(unsafe.Slice((*uint8)(src.UnsafePointer()), src.Len()))
-				s := bs.Convert(reflect.TypeOf(""))
+				s := bytesToString(src)
 				if (dst.IsZero() || c.overwrite) && (!s.IsZero() || c.overwriteWithEmptyValue) {
 					if c.typeCheck && c.overwrite {
 						if dst.Type() != src.Type() {
@@ -515,8 +841,7 @@ func deepValueMap(path string, dst, src reflect.Value, visited map[visit]string,
 				}
 				return nil
 			case reflect.Int32:
-				rs := reflect.ValueOf(unsafe.Slice((*int32)(src.UnsafePointer()), src.Len()))
-				s := rs.Convert(reflect.TypeOf(""))
+				s := runesToString(src)
 				if (dst.IsZero() || c.overwrite) && (!s.IsZero() || c.overwriteWithEmptyValue) {
 					if c.typeCheck && c.overwrite {
 						if dst.Type() != src.Type() {
@@ -546,20 +871,19 @@ func deepValueMap(path string, dst, src reflect.Value, visited map[visit]string,
 			}
 			i = int64(src.Uint())
 		case reflect.Float32, reflect.Float64:
-			if src.Float() != float64(int64(src.Float())) {
+			var ok bool
+			if i, ok = floatToInt(src.Float(), c.truncateNumeric); !ok {
 				return fmt.Errorf("%f cannot be represented as an %s", src.Float(), dst.Kind().String())
 			}
-			i = int64(src.Float())
 		case reflect.Complex64, reflect.Complex128:
 			if imag(src.Complex()) != 0 {
 				return fmt.Errorf("%f cannot be represented as an %s", src.Complex(), dst.Kind().String())
 			}
 
-			f := real(src.Complex())
-			if f != float64(int64(f)) {
+			var ok bool
+			if i, ok = floatToInt(real(src.Complex()), c.truncateNumeric); !ok {
 				return fmt.Errorf("%f cannot be represented as an %s", src.Complex(), dst.Kind().String())
 			}
-			i = int64(f)
 		}
 
 		if dst.OverflowInt(i) {
@@ -568,7 +892,7 @@ func deepValueMap(path string, dst, src reflect.Value, visited map[visit]string,
 
 		if (dst.IsZero() || c.overwrite) && (i != 0 || c.overwriteWithEmptyValue) {
 			if c.typeCheck && c.overwrite {
-				if dst.Type() != src.Type() {
+				if !typeCheckCompatible(dst.Type(), src.Type()) {
 					return fmt.Errorf("overwrite two different types %s <- %s", dst.Type(), src.Type())
 				}
 			}
@@ -590,20 +914,19 @@ func deepValueMap(path string, dst, src reflect.Value, visited map[visit]string,
 			}
 			i = uint64(src.Int())
 		case reflect.Float32, reflect.Float64:
-			if src.Float() != float64(uint64(src.Float())) {
+			var ok bool
+			if i, ok = floatToUint(src.Float(), c.truncateNumeric); !ok {
 				return fmt.Errorf("%f cannot be represented as an %s", src.Float(), dst.Kind().String())
 			}
-			i = uint64(src.Float())
 		case reflect.Complex64, reflect.Complex128:
 			if imag(src.Complex()) != 0 {
 				return fmt.Errorf("%f cannot be represented as an %s", src.Complex(), dst.Kind().String())
 			}
 
-			f := real(src.Complex())
-			if f != float64(uint64(f)) {
+			var ok bool
+			if i, ok = floatToUint(real(src.Complex()), c.truncateNumeric); !ok {
 				return fmt.Errorf("%f cannot be represented as an %s", src.Complex(), dst.Kind().String())
 			}
-			i = uint64(f)
 		}
 
 		if dst.OverflowUint(i) {
@@ -612,7 +935,7 @@ func deepValueMap(path string, dst, src reflect.Value, visited map[visit]string,
 
 		if (dst.IsZero() || c.overwrite) && (i != 0 || c.overwriteWithEmptyValue) {
 			if c.typeCheck && c.overwrite {
-				if dst.Type() != src.Type() {
+				if !typeCheckCompatible(dst.Type(), src.Type()) {
 					return fmt.Errorf("overwrite two different types %s <- %s", dst.Type(), src.Type())
 				}
 			}
@@ -628,14 +951,17 @@ func deepValueMap(path string, dst, src reflect.Value, visited map[visit]string,
 		default:
 			return fmt.Errorf("%s can not represents %s", dst.Kind().String(), src.Kind().String())
 		case reflect.Float32, reflect.Float64:
+			if nan, err := c.checkNaN(path, src); nan {
+				return err
+			}
 			f = src.Float()
 		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-			if src.Int() != int64(float64(src.Int())) {
+			if src.Int() != int64(float64(src.Int())) && !c.truncateNumeric {
 				return fmt.Errorf("%d cannot be represented as an %s", src.Int(), dst.Kind().String())
 			}
 			f = float64(src.Int())
 		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
-			if src.Uint() != uint64(float64(src.Uint())) {
+			if src.Uint() != uint64(float64(src.Uint())) && !c.truncateNumeric {
 				return fmt.Errorf("%d cannot be represented as an %s", src.Uint(), dst.Kind().String())
 			}
 			f = float64(src.Uint())
@@ -644,16 +970,18 @@ func deepValueMap(path string, dst, src reflect.Value, visited map[visit]string,
 		if dst.OverflowFloat(f) {
 			return fmt.Errorf("%f overflow %s", f, dst.Kind().String())
 		}
-
 		if (dst.IsZero() || c.overwrite) && (f != 0 || c.overwriteWithEmptyValue) {
+			if !dst.IsZero() && c.withinFloatTolerance(dst, f) {
+				return nil
+			}
 			if c.typeCheck && c.overwrite {
-				if dst.Type() != src.Type() {
+				if !typeCheckCompatible(dst.Type(), src.Type()) {
 					return fmt.Errorf("overwrite two different types %s <- %s", dst.Type(), src.Type())
 				}
 			}
 
 			debugf("%q (%s, %#v) <- (%s, %#v)\n", path, dst.Type(), dst, src.Type(), src)
-			dst.SetFloat(f)
+			dst.SetFloat(c.roundFloat(f))
 		}
 
 		return nil
@@ -670,6 +998,15 @@ func deepValueMap(path string, dst, src reflect.Value, visited map[visit]string,
 			c1 = complex(float64(src.Int()), 0)
 		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
 			c1 = complex(float64(src.Uint()), 0)
+		case reflect.Struct, reflect.Map:
+			if !c.polarComplex {
+				return fmt.Errorf("%s can not represents %s", dst.Kind().String(), src.Kind().String())
+			}
+
+			var err error
+			if c1, err = polarComplex(src); err != nil {
+				return fmt.Errorf("%q: %v", path, err)
+			}
 		}
 
 		if dst.OverflowComplex(c1) {
@@ -678,7 +1015,7 @@ func deepValueMap(path string, dst, src reflect.Value, visited map[visit]string,
 
 		if (dst.IsZero() || c.overwrite) && (c1 != complex128(0) || c.overwriteWithEmptyValue) {
 			if c.typeCheck && c.overwrite {
-				if dst.Type() != src.Type() {
+				if !typeCheckCompatible(dst.Type(), src.Type()) {
 					return fmt.Errorf("overwrite two different types %s <- %s", dst.Type(), src.Type())
 				}
 			}
@@ -688,6 +1025,16 @@ func deepValueMap(path string, dst, src reflect.Value, visited map[visit]string,
 		}
 
 		return nil
+	case reflect.Chan:
+		if ChannelOverwriteNonNil == c.channelPolicy && reflect.Chan == src.Kind() {
+			if dst.IsNil() && !src.IsNil() {
+				if !src.Type().AssignableTo(dst.Type()) {
+					return fmt.Errorf("%s is not assignable to %s", src.Type().String(), dst.Type().String())
+				}
+				dst.Set(src)
+			}
+			return nil
+		}
 	default:
 	}
 
@@ -704,7 +1051,7 @@ func deepValueMap(path string, dst, src reflect.Value, visited map[visit]string,
 
 	if (dst.IsZero() || c.overwrite) && (!src.IsZero() || c.overwriteWithEmptyValue) {
 		if c.typeCheck && c.overwrite {
-			if dt != st {
+			if !typeCheckCompatible(dt, st) {
 				return fmt.Errorf("overwrite two different types %s <- %s", dt, st)
 			}
 		}
@@ -719,6 +1066,250 @@ func deepValueMap(path string, dst, src reflect.Value, visited map[visit]string,
 	return nil
 }
 
+// mapSliceByKey deeply maps the elements of the slice or array src into the map dst,
+// keyed by the value of the element field named by WithSliceToMapByKey.
+func (c *Config) mapSliceByKey(path string, dst, src reflect.Value, visited map[visit]string) error {
+	et := src.Type().Elem()
+	if dst.Type().Elem() != et {
+		return fmt.Errorf("%s cannot be represents %s", dst.Type().String(), src.Type().String())
+	}
+
+	st := et
+	if reflect.Pointer == st.Kind() {
+		st = st.Elem()
+	}
+	if reflect.Struct != st.Kind() {
+		return fmt.Errorf("%s elements have no field %s", src.Type().String(), c.sliceKeyField)
+	}
+	kf, ok := st.FieldByName(c.sliceKeyField)
+	if !ok {
+		return fmt.Errorf("%s has no field %s", st.String(), c.sliceKeyField)
+	}
+	kt := dst.Type().Key()
+	if !kf.Type.ConvertibleTo(kt) {
+		return fmt.Errorf("field %s of type %s cannot be used as %s key", kf.Name, kf.Type.String(), kt.String())
+	}
+
+	if dst.IsNil() && src.Len() > 0 {
+		dst.Set(c.makeMap(dst.Type(), src.Len()))
+	}
+
+	for i := 0; i < src.Len(); i++ {
+		se := src.Index(i)
+		sv := se
+		if reflect.Pointer == sv.Kind() {
+			if sv.IsNil() {
+				continue
+			}
+			sv = sv.Elem()
+		}
+		k := sv.FieldByIndex(kf.Index).Convert(kt)
+
+		old := dst.MapIndex(k)
+		de := c.new(et).Elem()
+		if old.IsValid() {
+			de.Set(old)
+		}
+
+		if err := deepValueMap(fmt.Sprintf("%s[%v]", path, k), de, se, visited, c); err != nil {
+			return err
+		}
+		c.setMapIndex(dst, k, de, old)
+	}
+	return nil
+}
+
+// polarComplex returns the complex number given in polar form by the
+// Magnitude and Phase fields or keys of the struct or map v.
+func polarComplex(v reflect.Value) (complex128, error) {
+	polar := [2]float64{}
+	for i, name := range [...]string{"Magnitude", "Phase"} {
+		var f reflect.Value
+		if reflect.Struct == v.Kind() {
+			f = v.FieldByName(name)
+		} else {
+			f = mapIndexByFieldName(v, name)
+		}
+		if !f.IsValid() {
+			return 0, fmt.Errorf("polar form %s has no %s", v.Type().String(), name)
+		}
+		if reflect.Interface == f.Kind() {
+			f = f.Elem()
+		}
+
+		switch {
+		case f.CanFloat():
+			polar[i] = f.Float()
+		case f.CanInt():
+			polar[i] = float64(f.Int())
+		case f.CanUint():
+			polar[i] = float64(f.Uint())
+		default:
+			return 0, fmt.Errorf("polar form %s is not a number", name)
+		}
+	}
+	return cmplx.Rect(polar[0], polar[1]), nil
+}
+
+// floatToInt returns f as an int64. If truncate is set, the fractional part
+// of f is discarded (rounding toward zero); otherwise f must be integral.
+// It reports false if f can not be represented.
+func floatToInt(f float64, truncate bool) (int64, bool) {
+	if truncate {
+		f = math.Trunc(f)
+	}
+	if math.IsNaN(f) || f != math.Trunc(f) || f < math.MinInt64 || f >= 1<<63 {
+		return 0, false
+	}
+	return int64(f), true
+}
+
+// floatToUint is like floatToInt but returns a uint64.
+func floatToUint(f float64, truncate bool) (uint64, bool) {
+	if truncate {
+		f = math.Trunc(f)
+	}
+	if math.IsNaN(f) || f != math.Trunc(f) || f < 0 || f >= 1<<64 {
+		return 0, false
+	}
+	return uint64(f), true
+}
+
+// mapIndexByFieldName returns the value in m keyed by the field name,
+// or by the field name in lower camel case if the former is not present.
+// Maps whose keys are not strings have no such values.
+func mapIndexByFieldName(m reflect.Value, name string) reflect.Value {
+	kt := m.Type().Key()
+	if reflect.String != kt.Kind() {
+		return reflect.Value{}
+	}
+
+	if v := m.MapIndex(reflect.ValueOf(name).Convert(kt)); v.IsValid() {
+		return v
+	}
+
+	r, size := utf8.DecodeRuneInString(name)
+	return m.MapIndex(reflect.ValueOf(string(unicode.ToLower(r)) + name[size:]).Convert(kt))
+}
+
+// key returns the map key for the field name in style s.
+func (s KeyStyle) key(name string) string {
+	switch s {
+	default:
+		r, size := utf8.DecodeRuneInString(name)
+		return string(unicode.ToLower(r)) + name[size:]
+	case KeyStyleExact:
+		return name
+	case KeyStyleSnake:
+		return snakeCase(name)
+	}
+}
+
+// snakeCase converts the field name to snake case, keeping acronyms together:
+// FieldName becomes field_name and HTTPServer becomes http_server. A lowercase s
+// ending a word after an acronym is its plural, so MyURLs becomes my_urls.
+func snakeCase(name string) string {
+	rs := []rune(name)
+	var b strings.Builder
+	for i, r := range rs {
+		if unicode.IsUpper(r) && i > 0 {
+			prev := rs[i-1]
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) ||
+				(unicode.IsUpper(prev) && i+1 < len(rs) && unicode.IsLower(rs[i+1]) && !isPluralS(rs, i+1)) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// isPluralS reports whether rs[i] is an s that ends a word.
+func isPluralS(rs []rune, i int) bool {
+	return 's' == rs[i] && (i+1 == len(rs) || !unicode.IsLower(rs[i+1]))
+}
+
+// fieldByIndexAlloc is like v.FieldByIndex but allocates nil embedded pointers
+// on the way. It reports false if a nil embedded pointer can not be set.
+func (c *Config) fieldByIndexAlloc(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && reflect.Pointer == v.Kind() {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, false
+				}
+				v.Set(c.new(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// mergeStructByName deeply merges the exported fields of the struct src with merge,
+// deepValueMerge or deepValueMap, into the fields of the struct dst with the same names
+// or the names aliased by WithFieldAlias. fieldPath returns the path of a dst field.
+func (c *Config) mergeStructByName(path string, dst, src reflect.Value, visited map[visit]string,
+	merge func(string, reflect.Value, reflect.Value, map[visit]string, *Config) error,
+	fieldPath func(path, name string) string) error {
+	for i, sf := range cachedFields(src.Type()).list {
+		if !sf.IsExported() {
+			continue
+		}
+
+		name := sf.Name
+		if alias, ok := c.fieldAliases[name]; ok {
+			name = alias
+		}
+		df, ok := dst.Type().FieldByName(name)
+		if !ok || !df.IsExported() {
+			continue
+		}
+
+		fp := fieldPath(path, df.Name)
+		if !c.filterField(fp, df) {
+			continue
+		}
+
+		de, ok := c.fieldByIndexAlloc(dst, df.Index)
+		if !ok {
+			continue
+		}
+		if err := merge(fp, de, src.Field(i), visited, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeFieldPath and mapFieldPath return the path of the field name
+// of the struct at path in deepValueMerge and deepValueMap.
+func mergeFieldPath(path, name string) string { return path + "." + name }
+func mapFieldPath(path, name string) string   { return path + "[" + name + "]" }
+
+// sliceOfArray returns a slice of the array v, of a copy of v if v is not addressable.
+func sliceOfArray(v reflect.Value) reflect.Value {
+	if !v.CanAddr() {
+		a := reflect.New(v.Type()).Elem()
+		a.Set(v)
+		v = a
+	}
+	return v.Slice(0, v.Len())
+}
+
+func equalIndex(x, y []int) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
+}
+
 // DeepMap “deeply map,” the contents of src into dst defined as follows.
 // Two values of identical kind are always deeply map if one of the following cases applies.
 // Values of distinct kinds can may be deeply map.
@@ -749,6 +1340,9 @@ func deepValueMap(path string, dst, src reflect.Value, visited map[visit]string,
 //
 // Numeric types values deeply map without precision lost and overflow.
 //
+// Slices of bytes deeply map to values implementing encoding.BinaryUnmarshaler
+// by calling UnmarshalBinary.
+//
 // String values alse deeply map from a signed or unsigned integer value, slices of bytes,
 // and slices of runes.
 //
@@ -764,10 +1358,18 @@ func deepValueMap(path string, dst, src reflect.Value, visited map[visit]string,
 // second and subsequent times that DeepMap compares two pointer
 // values that have been mapped before, it treats the values as
 // mapped rather than examining the values to which they point.
+// The same applies to maps and slices, including maps that contain
+// themselves through interface values.
 // This ensures that DeepMap terminates.
-func DeepMap(dst, src any, opts ...Option) error {
-	if dst == nil || src == nil {
-		return errors.New("dst or src is nil")
+func DeepMap(dst, src any, opts ...Option) (err error) {
+	var c Config
+	Options(opts).apply(&c)
+	if err := c.validate(); err != nil {
+		return err
+	}
+	defer c.recoverPanic(&err)
+	if noop, err := c.checkNil(dst, src); noop || err != nil {
+		return err
 	}
 
 	vdst := reflect.ValueOf(dst)
@@ -789,9 +1391,12 @@ func DeepMap(dst, src any, opts ...Option) error {
 		vdst = vdst.Elem()
 		if reflect.Pointer == vdst.Kind() {
 			if vdst.IsNil() {
-				p := reflect.New(vdst.Type().Elem())
-				debugf("SetPointer %s %p", p.Elem().Type(), p.UnsafePointer())
-				vdst.Set(p)
+				p := c.new(vdst.Type().Elem())
+				debugf("SetPointer %s %p", p.Elem().Type(), p.Interface())
+				if !c.dryRun {
+					vdst.Set(p)
+				}
+				vdst = p
 			}
 			vdst = vdst.Elem()
 		}
@@ -806,18 +1411,71 @@ func DeepMap(dst, src any, opts ...Option) error {
 		switch vdst.Kind() {
 		default:
 			return errors.New("dst was expected to be a struct or a map")
-		case reflect.Struct, reflect.Map:
+		case reflect.Struct, reflect.Map, reflect.Interface:
 		}
 	case reflect.Map:
 		switch vdst.Kind() {
 		default:
 			return errors.New("dst was expected to be a map or a struct")
-		case reflect.Map, reflect.Struct:
+		case reflect.Map, reflect.Struct, reflect.Interface:
 		}
 	}
 
+	return c.mapValue(vdst, vsrc)
+}
+
+// MapValue is like DeepMap for reflected values: it deeply maps src into dst,
+// which must be addressable and settable, e.g. reflect.ValueOf(&v).Elem().
+func MapValue(dst, src reflect.Value, opts ...Option) (err error) {
+	if !dst.IsValid() || !src.IsValid() {
+		return errors.New("dst or src is invalid")
+	}
+	if !dst.CanSet() {
+		return errors.New("dst must be addressable and settable")
+	}
+
 	var c Config
 	Options(opts).apply(&c)
+	if err := c.validate(); err != nil {
+		return err
+	}
+	defer c.recoverPanic(&err)
+	return c.mapValue(dst, src)
+}
 
-	return deepValueMap("", vdst, vsrc, make(map[visit]string), &c)
+// mapValue deeply maps vsrc into vdst for DeepMap and MapValue.
+func (c *Config) mapValue(vdst, vsrc reflect.Value) error {
+	if c.onceSet != nil {
+		return errors.New("WithImmutableOnceSet is not supported by DeepMap")
+	}
+
+	// deepValueMap does not record its assignments, so a dry run maps
+	// into a copy of dst and records how the copy differs from dst.
+	if c.dryRun {
+		cp := reflect.New(vdst.Type()).Elem()
+		cp.Set(c.copyOf(vdst))
+		cc := c.clone()
+		cc.dryRun, cc.changes = false, nil
+		if err := cc.mapValue(cp, vsrc); err != nil {
+			return err
+		}
+		if c.changes != nil {
+			var d differ
+			deepValueDiff("", vdst, cp, make(map[diffVisit]bool), &d, &Config{unexportedFields: c.unexportedFields, sortedMapKeys: c.sortedMapKeys})
+			*c.changes = append(*c.changes, d.changes...)
+		}
+		return nil
+	}
+
+	// Struct fields are recorded as they are mapped, for filterField.
+	if c.fieldFilter != nil || len(c.scopes) > 0 {
+		c.mapFieldPaths = make(map[string]bool)
+	}
+	if err := deepValueMap("", vdst, vsrc, make(map[visit]string), c); err != nil {
+		return err
+	}
+	if c.assertIdempotent {
+		return c.checkIdempotent(vdst, vsrc, deepValueMap)
+	}
+	return nil
 }
@@ -2,6 +2,11 @@ package merge_test
 
 import (
 	"encoding/json"
+	"fmt"
+	"math"
+	"math/cmplx"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -87,7 +92,58 @@ func TestNumericTypes(t *testing.T) {
 		},
 	}
 
-	testDeepMap(t, tests...)
+	t.Run("Map", func(t *testing.T) { testDeepMap(t, tests...) })
+
+	t.Run("Merge", func(t *testing.T) {
+		tests := append([]test(nil), tests...)
+		for i := range tests {
+			tests[i].mergeOpts = append(tests[i].mergeOpts, WithNumericConvert())
+		}
+		tests = append(tests,
+			test{
+				name:      "int to int32",
+				dst:       New(int32(0)),
+				src:       1,
+				mergeOpts: Options{WithNumericConvert()},
+				want:      New(int32(1)),
+			},
+			test{
+				name:      "int to int8 overflow",
+				dst:       New(int8(0)),
+				src:       128,
+				mergeOpts: Options{WithNumericConvert()},
+				wantErr:   true,
+			},
+			test{
+				name:      "nested int to int32",
+				dst:       map[string]any{"n": int32(0)},
+				src:       map[string]any{"n": 1},
+				mergeOpts: Options{WithNumericConvert()},
+				want:      map[string]any{"n": int32(1)},
+			},
+			test{
+				name:      "keep non-zero dst",
+				dst:       New(int32(2)),
+				src:       1,
+				mergeOpts: Options{WithNumericConvert()},
+				want:      New(int32(2)),
+			},
+			test{
+				name:      "overwrite",
+				dst:       New(float32(2)),
+				src:       1,
+				mergeOpts: Options{WithNumericConvert(), WithOverwrite()},
+				want:      New(float32(1)),
+			},
+			test{
+				name:    "without option",
+				dst:     New(int32(0)),
+				src:     1,
+				wantErr: true,
+			},
+		)
+		testDeepMerge(t, tests...)
+	})
 }
 
 func TestIntToString(t *testing.T) {
@@ -115,6 +171,36 @@ func TestIntToString(t *testing.T) {
 			src:  '\u00E4',
 			want: New(MyString("ä")),
 		},
+		{
+			name: "max rune",
+			dst:  New(""),
+			src:  uint32(0x10FFFF),
+			want: New("\U0010FFFF"),
+		},
+		{
+			name:    "beyond max rune",
+			dst:     New(""),
+			src:     uint32(0x110000),
+			wantErr: true,
+		},
+		{
+			name:    "surrogate",
+			dst:     New(""),
+			src:     uint16(0xD800),
+			wantErr: true,
+		},
+		{
+			name:    "negative",
+			dst:     New(""),
+			src:     -1,
+			wantErr: true,
+		},
+		{
+			name:    "large uint",
+			dst:     New(""),
+			src:     uint64(1<<32 + 'x'),
+			wantErr: true,
+		},
 	}
 
 	testDeepMap(t, tests...)
@@ -181,6 +267,82 @@ func TestBytesToString(t *testing.T) {
 	testDeepMap(t, tests...)
 }
 
+func TestStringToBytes(t *testing.T) {
+	t.Parallel()
+
+	type (
+		MyString string
+		MyBytes  []byte
+		MyByte   byte
+	)
+	type T struct{ Token []byte }
+	tests := []test{
+		{
+			dst:  New([]byte(nil)),
+			src:  "hellø",
+			want: New([]byte{'h', 'e', 'l', 'l', '\xc3', '\xb8'}),
+		},
+		{
+			dst:  New(MyBytes(nil)),
+			src:  MyString("hellø"),
+			want: New(MyBytes{'h', 'e', 'l', 'l', '\xc3', '\xb8'}),
+		},
+		{
+			dst:  &T{},
+			src:  map[string]any{"token": "secret"},
+			want: &T{[]byte("secret")},
+		},
+		{
+			name:      "overwrite shorter",
+			dst:       &T{[]byte("longer")},
+			src:       map[string]any{"token": "tok"},
+			mergeOpts: Options{WithOverwrite()},
+			want:      &T{[]byte("tok")},
+		},
+		{
+			name: "keep",
+			dst:  &T{[]byte("old")},
+			src:  map[string]any{"token": "new"},
+			want: &T{[]byte("old")},
+		},
+		{
+			name:      "type check",
+			dst:       &T{[]byte("old")},
+			src:       map[string]any{"token": "new"},
+			mergeOpts: Options{WithOverwrite(), WithTypeCheck()},
+			wantErr:   true,
+		},
+		{
+			name:      "append",
+			dst:       New([]byte("foo")),
+			src:       "bar",
+			mergeOpts: Options{WithAppendSlice()},
+			want:      New([]byte("foobar")),
+		},
+		{
+			name: "not convertible",
+			dst:  New([]MyByte(nil)),
+			src:  "hi",
+			want: New([]MyByte{'h', 'i'}),
+		},
+
+		// zero values
+		{
+			dst:  New([]byte("foo")),
+			src:  "",
+			want: New([]byte("foo")),
+		},
+		{
+			dst:       New([]byte("foo")),
+			src:       "",
+			mergeOpts: Options{WithOverwriteWithEmptyValue()},
+			want:      New([]byte{}),
+		},
+	}
+
+	testDeepMap(t, tests...)
+}
+
 func TestRunesToString(t *testing.T) {
 	t.Parallel()
 
@@ -242,6 +404,65 @@ func TestRunesToString(t *testing.T) {
 	testDeepMap(t, tests...)
 }
 
+func TestArraysToString(t *testing.T) {
+	t.Parallel()
+
+	type (
+		MyString string
+		MyRune   rune
+	)
+	type T struct{ S string }
+	tests := []test{
+		{
+			dst:  New(MyString("")),
+			src:  [6]byte{'h', 'e', 'l', 'l', '\xc3', '\xb8'},
+			want: New(MyString("hellø")),
+		},
+		{
+			dst:  New(""),
+			src:  [2]rune{0x266b, 0x266c},
+			want: New("♫♬"),
+		},
+		{
+			dst:  New(""),
+			src:  [2]MyRune{0x266b, 0x266c},
+			want: New("♫♬"),
+		},
+		{
+			dst:  &T{},
+			src:  struct{ S [3]byte }{[3]byte{'f', 'o', 'o'}},
+			want: &T{"foo"},
+		},
+		{
+			dst:  &T{},
+			src:  map[string]any{"s": [3]byte{'b', 'a', 'r'}},
+			want: &T{"bar"},
+		},
+		{
+			name:      "overwrite",
+			dst:       New("foo"),
+			src:       [3]byte{'b', 'a', 'r'},
+			mergeOpts: Options{WithOverwrite()},
+			want:      New("bar"),
+		},
+
+		// zero values
+		{
+			dst:  New("foo"),
+			src:  [0]byte{},
+			want: New("foo"),
+		},
+		{
+			dst:       New("foo"),
+			src:       [0]rune{},
+			mergeOpts: Options{WithOverwriteWithEmptyValue()},
+			want:      New(""),
+		},
+	}
+
+	testDeepMap(t, tests...)
+}
+
 func TestMapMap(t *testing.T) {
 	t.Parallel()
 
@@ -284,6 +505,42 @@ func TestMapMap(t *testing.T) {
 	testDeepMap(t, test)
 }
 
+func TestMapPartialNestedStruct(t *testing.T) {
+	t.Parallel()
+
+	type T struct{ A, B int }
+	type T2 struct {
+		A string
+		B T
+		c int
+	}
+	type T3 struct{ A T2 }
+
+	tests := []test{
+		{
+			dst: &T3{A: T2{A: "foo", B: T{1, 2}, c: 5}},
+			src: map[string]any{
+				"a": map[string]any{
+					"a": "bar",
+					"b": map[string]any{"b": 3},
+					"c": 1, // unexported, ignored
+				},
+			},
+			mergeOpts: Options{WithOverwrite()},
+			want:      &T3{A: T2{A: "bar", B: T{1, 3}, c: 5}},
+			cmpOpts:   cmp.Options{cmp.AllowUnexported(T2{})},
+		},
+		{
+			dst:     &T3{A: T2{A: "foo", B: T{1, 2}, c: 5}},
+			src:     map[string]any{"a": map[string]any{"b": map[string]any{"a": 4, "b": 3}}},
+			want:    &T3{A: T2{A: "foo", B: T{1, 2}, c: 5}},
+			cmpOpts: cmp.Options{cmp.AllowUnexported(T2{})},
+		},
+	}
+
+	testDeepMap(t, tests...)
+}
+
 func TestSimpleMap(t *testing.T) {
 	t.Parallel()
 
@@ -486,6 +743,106 @@ func TestIssue138(t *testing.T) {
 	testDeepMap(t, tests...)
 }
 
+func TestMapAnyMapIntoTypedMap(t *testing.T) {
+	t.Parallel()
+
+	type T struct {
+		M map[string]int
+		P map[string]*int
+	}
+
+	tests := []test{
+		{
+			dst:  &map[string]int{"a": 1},
+			src:  map[string]any{"b": 2, "c": 3.0, "d": uint8(4), "e": nil},
+			want: &map[string]int{"a": 1, "b": 2, "c": 3, "d": 4},
+		},
+		{
+			dst:  &T{M: map[string]int{"a": 1}},
+			src:  map[string]any{"M": map[string]any{"a": 5, "b": 2}, "P": map[string]any{"a": New(3)}},
+			want: &T{M: map[string]int{"a": 1, "b": 2}, P: map[string]*int{"a": New(3)}},
+		},
+		{
+			dst:       &map[string]int{"a": 1},
+			src:       map[string]any{"a": 5},
+			mergeOpts: Options{WithOverwrite()},
+			want:      &map[string]int{"a": 5},
+		},
+		{dst: &map[string]int8{}, src: map[string]any{"a": 1 << 10}, wantErr: true},
+		{dst: &map[string]int{}, src: map[string]any{"a": 1.5}, wantErr: true},
+		{dst: &map[string]int{}, src: map[string]any{"a": "x"}, wantErr: true},
+	}
+
+	testDeepMap(t, tests...)
+}
+
+func TestIssue138UseNumber(t *testing.T) {
+	t.Parallel()
+
+	const js = `{"Port": 80, "Ratio": 1.5, "Big": 18446744073709551615, "Neg": -1}`
+
+	var m = make(map[string]any)
+	// With UseNumber, encoding/json unmarshals numbers as json.Number
+	d := json.NewDecoder(strings.NewReader(js))
+	d.UseNumber()
+	if err := d.Decode(&m); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []test{
+		{
+			dst:  &struct{ Port int }{},
+			src:  m,
+			want: &struct{ Port int }{80},
+		},
+		{
+			dst:  &struct{ Port float64 }{},
+			src:  m,
+			want: &struct{ Port float64 }{80},
+		},
+		{
+			dst:  &struct{ Port uint8 }{},
+			src:  m,
+			want: &struct{ Port uint8 }{80},
+		},
+		{
+			dst:  &struct{ Ratio float32 }{},
+			src:  m,
+			want: &struct{ Ratio float32 }{1.5},
+		},
+		{
+			dst:  &struct{ Big uint64 }{},
+			src:  m,
+			want: &struct{ Big uint64 }{math.MaxUint64},
+		},
+		{
+			dst:  &struct{ Port json.Number }{},
+			src:  m,
+			want: &struct{ Port json.Number }{"80"},
+		},
+		{
+			name:    "fraction into int",
+			dst:     &struct{ Ratio int }{},
+			src:     m,
+			wantErr: true,
+		},
+		{
+			name:    "negative into uint",
+			dst:     &struct{ Neg uint }{},
+			src:     m,
+			wantErr: true,
+		},
+		{
+			name:    "invalid",
+			dst:     New(0),
+			src:     json.Number("eighty"),
+			wantErr: true,
+		},
+	}
+
+	testDeepMap(t, tests...)
+}
+
 func TestIssue143(t *testing.T) {
 	t.Parallel()
 
@@ -580,3 +937,668 @@ func TestV039Issue152(t *testing.T) {
 		mergeOpts: Options{WithOverwrite()},
 	})
 }
+
+func TestMapToStructPromotedFields(t *testing.T) {
+	t.Parallel()
+
+	type Base struct {
+		Name  string
+		Count int
+	}
+	type Outer struct {
+		Base
+		Name string
+	}
+
+	tests := []test{
+		{
+			name: "outer field shadows embedded field",
+			dst:  &Outer{},
+			src:  map[string]any{"name": "outer", "count": 1},
+			want: &Outer{Base: Base{Count: 1}, Name: "outer"},
+		},
+		{
+			name: "embedding field mapped as a whole",
+			dst:  &Outer{},
+			src:  map[string]any{"Base": map[string]any{"name": "base"}, "count": 1},
+			want: &Outer{Base: Base{Name: "base"}},
+		},
+	}
+
+	testDeepMap(t, tests...)
+}
+
+type binaryPoint struct{ X, Y int }
+
+func (p *binaryPoint) UnmarshalBinary(data []byte) error {
+	if len(data) != 2 {
+		return fmt.Errorf("binaryPoint: invalid length %d", len(data))
+	}
+	p.X, p.Y = int(data[0]), int(data[1])
+	return nil
+}
+
+func TestBinaryUnmarshaler(t *testing.T) {
+	t.Parallel()
+
+	type T struct {
+		P   binaryPoint
+		Ptr *binaryPoint
+	}
+
+	tests := []test{
+		{
+			dst:  &T{},
+			src:  map[string]any{"p": []byte{1, 2}, "ptr": []byte{3, 4}},
+			want: &T{binaryPoint{1, 2}, &binaryPoint{3, 4}},
+		},
+		{
+			dst:  &T{P: binaryPoint{5, 6}},
+			src:  map[string]any{"p": []byte{1, 2}},
+			want: &T{P: binaryPoint{5, 6}},
+		},
+		{
+			dst:       &T{P: binaryPoint{5, 6}},
+			src:       map[string]any{"p": []byte{1, 2}},
+			mergeOpts: Options{WithOverwrite()},
+			want:      &T{P: binaryPoint{1, 2}},
+		},
+		{
+			dst:     &T{},
+			src:     map[string]any{"p": []byte{1}},
+			wantErr: true,
+		},
+	}
+
+	testDeepMap(t, tests...)
+}
+
+func TestEnumStringMap(t *testing.T) {
+	t.Parallel()
+
+	type Status uint8
+	type T struct {
+		Status Status
+		Name   string
+	}
+
+	enum := WithEnumStringMap(reflect.TypeOf(Status(0)), map[string]int64{
+		"inactive": 0,
+		"active":   1,
+		"banned":   2,
+	})
+
+	tests := []test{
+		{
+			dst:       &T{},
+			src:       map[string]any{"status": "active", "name": "active"},
+			mergeOpts: Options{enum},
+			want:      &T{Status: 1, Name: "active"},
+		},
+		{
+			dst:       &T{Status: 1},
+			src:       map[string]any{"status": "banned"},
+			mergeOpts: Options{enum, WithOverwrite()},
+			want:      &T{Status: 2},
+		},
+		{
+			dst:       &T{Status: 1},
+			src:       map[string]any{"status": "inactive"},
+			mergeOpts: Options{enum, WithOverwrite()},
+			want:      &T{Status: 1},
+		},
+		{
+			dst:       &T{},
+			src:       map[string]any{"status": "unknown"},
+			mergeOpts: Options{enum},
+			wantErr:   true,
+		},
+		{
+			dst: &T{},
+			src: map[string]any{"status": "huge"},
+			mergeOpts: Options{WithEnumStringMap(reflect.TypeOf(Status(0)), map[string]int64{
+				"huge": 256,
+			})},
+			wantErr: true,
+		},
+	}
+
+	testDeepMap(t, tests...)
+}
+
+func TestMapNonStringKeys(t *testing.T) {
+	t.Parallel()
+
+	type Foo struct{ A, B int }
+	type Key struct{ X, Y int }
+	type Name string
+	type T struct{ A int }
+
+	tests := []test{
+		{
+			dst:  map[int]Foo{1: {A: 1}},
+			src:  map[int]Foo{1: {B: 2}, 2: {A: 3}},
+			want: map[int]Foo{1: {A: 1, B: 2}, 2: {A: 3}},
+		},
+		{
+			dst:  map[Key]Foo{{1, 2}: {A: 1}},
+			src:  map[Key]Foo{{1, 2}: {B: 2}, {3, 4}: {A: 3}},
+			want: map[Key]Foo{{1, 2}: {A: 1, B: 2}, {3, 4}: {A: 3}},
+		},
+		{
+			name: "named string keys from struct",
+			dst:  map[Name]any{},
+			src:  T{42},
+			want: map[Name]any{"a": 42},
+		},
+		{
+			name: "named string keys to struct",
+			dst:  &T{},
+			src:  map[Name]any{"a": 42},
+			want: &T{42},
+		},
+		{
+			name:    "int keys from struct",
+			dst:     map[int]any{},
+			src:     T{42},
+			wantErr: true,
+		},
+		{
+			name:    "int keys from string keys",
+			dst:     &map[int]string{},
+			src:     map[string]string{"1": "a"},
+			wantErr: true,
+		},
+		{
+			name:    "string keys from any keys",
+			dst:     map[string]int{"a": 1},
+			src:     map[any]int{"a": 2},
+			wantErr: true,
+		},
+	}
+
+	testDeepMap(t, tests...)
+}
+
+func TestSliceToMapByKey(t *testing.T) {
+	t.Parallel()
+
+	type User struct {
+		ID   string
+		Name string
+		Age  int
+	}
+
+	users := []User{{"1", "alice", 30}, {"2", "bob", 0}}
+
+	tests := []test{
+		{
+			name:      "new map",
+			dst:       New(map[string]User(nil)),
+			src:       users,
+			mergeOpts: Options{WithSliceToMapByKey("ID")},
+			want:      New(map[string]User{"1": {"1", "alice", 30}, "2": {"2", "bob", 0}}),
+		},
+		{
+			name:      "existing map",
+			dst:       map[string]User{"2": {"2", "robert", 40}, "3": {"3", "carol", 50}},
+			src:       users,
+			mergeOpts: Options{WithSliceToMapByKey("ID")},
+			want: map[string]User{
+				"1": {"1", "alice", 30},
+				"2": {"2", "robert", 40},
+				"3": {"3", "carol", 50},
+			},
+		},
+		{
+			name:      "pointer elements",
+			dst:       map[string]*User{"2": {"2", "robert", 0}},
+			src:       []*User{{"2", "bob", 25}},
+			mergeOpts: Options{WithSliceToMapByKey("ID")},
+			want:      map[string]*User{"2": {"2", "robert", 25}},
+		},
+		{
+			name:      "unknown field",
+			dst:       map[string]User{},
+			src:       users,
+			mergeOpts: Options{WithSliceToMapByKey("Email")},
+			wantErr:   true,
+		},
+		{
+			name:    "without option",
+			dst:     map[string]User{},
+			src:     users,
+			wantErr: true,
+		},
+	}
+
+	testDeepMap(t, tests...)
+}
+
+func TestTypeCheckInterfaceConcreteType(t *testing.T) {
+	t.Parallel()
+
+	type T struct{ X any }
+	opts := Options{WithOverwrite(), WithTypeCheck()}
+
+	tests := []test{
+		{
+			name:      "same concrete type",
+			dst:       &T{1},
+			src:       T{2},
+			mergeOpts: opts,
+			want:      &T{2},
+		},
+		{
+			name:      "different concrete type",
+			dst:       &T{1},
+			src:       T{"str"},
+			mergeOpts: opts,
+			wantErr:   true,
+		},
+		{
+			name:      "different concrete type of same kind",
+			dst:       &T{1},
+			src:       T{int64(2)},
+			mergeOpts: opts,
+			wantErr:   true,
+		},
+		{
+			name:      "nil dst",
+			dst:       &T{},
+			src:       T{"str"},
+			mergeOpts: opts,
+			want:      &T{"str"},
+		},
+	}
+
+	t.Run("Merge", func(t *testing.T) { testDeepMerge(t, tests...) })
+
+	t.Run("Map", func(t *testing.T) {
+		testDeepMap(t, tests...)
+		testDeepMap(t, test{
+			name:      "from map",
+			dst:       &T{1},
+			src:       map[string]any{"x": "str"},
+			mergeOpts: opts,
+			wantErr:   true,
+		})
+	})
+}
+
+func TestUnixTimeFields(t *testing.T) {
+	t.Parallel()
+
+	type T struct{ Created time.Time }
+
+	sec := time.Unix(1700000000, 0)
+	tests := []test{
+		{
+			name:      "seconds",
+			dst:       &T{},
+			src:       map[string]any{"created": 1700000000},
+			mergeOpts: Options{WithUnixTimeFields(time.Second)},
+			want:      &T{sec},
+		},
+		{
+			name:      "millis",
+			dst:       &T{},
+			src:       map[string]any{"created": uint64(1700000000123)},
+			mergeOpts: Options{WithUnixTimeFields(time.Millisecond)},
+			want:      &T{time.UnixMilli(1700000000123)},
+		},
+		{
+			name:      "nanos",
+			dst:       &T{},
+			src:       map[string]any{"created": int64(1700000000123456789)},
+			mergeOpts: Options{WithUnixTimeFields(time.Nanosecond)},
+			want:      &T{time.Unix(0, 1700000000123456789)},
+		},
+		{
+			name:      "keep non-zero dst",
+			dst:       &T{sec},
+			src:       map[string]any{"created": 1},
+			mergeOpts: Options{WithUnixTimeFields(time.Second)},
+			want:      &T{sec},
+		},
+		{
+			name:      "out of range seconds",
+			dst:       &T{},
+			src:       map[string]any{"created": int64(math.MaxInt64)},
+			mergeOpts: Options{WithUnixTimeFields(time.Second)},
+			wantErr:   true,
+		},
+		{
+			name:      "out of range millis",
+			dst:       &T{},
+			src:       map[string]any{"created": int64(math.MinInt64)},
+			mergeOpts: Options{WithUnixTimeFields(time.Millisecond)},
+			wantErr:   true,
+		},
+		{
+			name:      "out of range uint",
+			dst:       &T{},
+			src:       map[string]any{"created": uint64(math.MaxUint64)},
+			mergeOpts: Options{WithUnixTimeFields(time.Nanosecond)},
+			wantErr:   true,
+		},
+	}
+
+	testDeepMap(t, tests...)
+}
+
+func TestPolarComplex(t *testing.T) {
+	t.Parallel()
+
+	type Polar struct{ Magnitude, Phase float64 }
+	type T struct{ Z complex128 }
+
+	tests := []test{
+		{
+			name:      "struct",
+			dst:       &T{},
+			src:       map[string]any{"z": Polar{2, math.Pi / 2}},
+			mergeOpts: Options{WithPolarComplex()},
+			want:      &T{cmplx.Rect(2, math.Pi/2)},
+		},
+		{
+			name:      "map",
+			dst:       &T{},
+			src:       map[string]any{"z": map[string]any{"magnitude": 1, "phase": math.Pi}},
+			mergeOpts: Options{WithPolarComplex()},
+			want:      &T{cmplx.Rect(1, math.Pi)},
+		},
+		{
+			name:      "missing phase",
+			dst:       &T{},
+			src:       map[string]any{"z": map[string]any{"magnitude": 1}},
+			mergeOpts: Options{WithPolarComplex()},
+			wantErr:   true,
+		},
+		{
+			name:    "without option",
+			dst:     &T{},
+			src:     map[string]any{"z": Polar{2, 0}},
+			wantErr: true,
+		},
+	}
+
+	testDeepMap(t, tests...)
+}
+
+func TestTruncateNumeric(t *testing.T) {
+	t.Parallel()
+
+	opts := Options{WithTruncateNumeric()}
+	tests := []test{
+		{
+			name:      "float64 to int",
+			dst:       New(int(0)),
+			src:       1.9,
+			mergeOpts: opts,
+			want:      New(int(1)),
+		},
+		{
+			name:      "negative float64 to int",
+			dst:       New(int(0)),
+			src:       -1.9,
+			mergeOpts: opts,
+			want:      New(int(-1)),
+		},
+		{
+			name:      "float64 to uint8",
+			dst:       New(uint8(0)),
+			src:       254.7,
+			mergeOpts: opts,
+			want:      New(uint8(254)),
+		},
+		{
+			name:      "complex128 to int",
+			dst:       New(int(0)),
+			src:       complex(2.5, 0),
+			mergeOpts: opts,
+			want:      New(int(2)),
+		},
+		{
+			name:      "int64 to float64",
+			dst:       New(float64(0)),
+			src:       int64(1<<53 + 1),
+			mergeOpts: opts,
+			want:      New(float64(1 << 53)),
+		},
+		{
+			name:      "float64 to uint8 overflow",
+			dst:       New(uint8(0)),
+			src:       256.5,
+			mergeOpts: opts,
+			wantErr:   true,
+		},
+		{
+			name:      "negative float64 to uint",
+			dst:       New(uint(0)),
+			src:       -1.5,
+			mergeOpts: opts,
+			wantErr:   true,
+		},
+		{
+			name:      "NaN to int",
+			dst:       New(int(0)),
+			src:       math.NaN(),
+			mergeOpts: opts,
+			wantErr:   true,
+		},
+		{
+			name:    "int64 to float64 without option",
+			dst:     New(float64(0)),
+			src:     int64(1<<53 + 1),
+			wantErr: true,
+		},
+	}
+
+	testDeepMap(t, tests...)
+}
+
+func TestMapKeyStyle(t *testing.T) {
+	t.Parallel()
+
+	type Config struct {
+		FieldName  string
+		HTTPServer string
+		UserID     int
+		V2API      bool
+		MyURLs     []string
+		IDsByName  map[string]int
+	}
+
+	src := Config{FieldName: "a", HTTPServer: "b", UserID: 1, V2API: true, MyURLs: []string{"u"}, IDsByName: map[string]int{"n": 2}}
+	tests := []test{
+		{
+			name: "camel",
+			dst:  map[string]any{},
+			src:  src,
+			want: map[string]any{
+				"fieldName": "a", "hTTPServer": "b", "userID": 1, "v2API": true,
+				"myURLs": []string{"u"}, "iDsByName": map[string]int{"n": 2},
+			},
+		},
+		{
+			name:      "snake",
+			dst:       map[string]any{},
+			src:       src,
+			mergeOpts: Options{WithMapKeyStyle(KeyStyleSnake)},
+			want: map[string]any{
+				"field_name": "a", "http_server": "b", "user_id": 1, "v2_api": true,
+				"my_urls": []string{"u"}, "ids_by_name": map[string]int{"n": 2},
+			},
+		},
+		{
+			name:      "exact",
+			dst:       map[string]any{},
+			src:       src,
+			mergeOpts: Options{WithMapKeyStyle(KeyStyleExact)},
+			want: map[string]any{
+				"FieldName": "a", "HTTPServer": "b", "UserID": 1, "V2API": true,
+				"MyURLs": []string{"u"}, "IDsByName": map[string]int{"n": 2},
+			},
+		},
+		{
+			name:      "existing exact key",
+			dst:       map[string]any{"FieldName": "x"},
+			src:       src,
+			mergeOpts: Options{WithMapKeyStyle(KeyStyleSnake), WithOverwrite()},
+			want: map[string]any{
+				"FieldName": "a", "http_server": "b", "user_id": 1, "v2_api": true,
+				"my_urls": []string{"u"}, "ids_by_name": map[string]int{"n": 2},
+			},
+		},
+	}
+
+	testDeepMap(t, tests...)
+}
+
+func TestInterfaceConvert(t *testing.T) {
+	t.Parallel()
+
+	type T struct{ V any }
+	type Name string
+
+	opts := Options{WithInterfaceConvert()}
+	tests := []test{
+		{
+			name:      "int into zero int64",
+			dst:       &T{int64(0)},
+			src:       map[string]any{"V": 1},
+			mergeOpts: opts,
+			want:      &T{int64(1)},
+		},
+		{
+			name:      "int into int64 with overwrite",
+			dst:       &T{int64(2)},
+			src:       map[string]any{"V": 1},
+			mergeOpts: append(Options{WithOverwrite()}, opts...),
+			want:      &T{int64(1)},
+		},
+		{
+			name:      "keep non-zero dst",
+			dst:       &T{int64(2)},
+			src:       map[string]any{"V": 1},
+			mergeOpts: opts,
+			want:      &T{int64(2)},
+		},
+		{
+			name:      "float into int64 not representable",
+			dst:       &T{int64(0)},
+			src:       map[string]any{"V": 1.5},
+			mergeOpts: opts,
+			wantErr:   true,
+		},
+		{
+			name:      "bytes into named string",
+			dst:       &T{Name("")},
+			src:       map[string]any{"V": []byte("foo")},
+			mergeOpts: opts,
+			want:      &T{Name("foo")},
+		},
+		{
+			name:      "without option",
+			dst:       &T{int64(2)},
+			src:       map[string]any{"V": 1},
+			mergeOpts: Options{WithOverwrite()},
+			want:      &T{1},
+		},
+		{
+			name:      "type check takes precedence",
+			dst:       &T{int64(2)},
+			src:       map[string]any{"V": 1},
+			mergeOpts: append(Options{WithOverwrite(), WithTypeCheck()}, opts...),
+			wantErr:   true,
+		},
+	}
+
+	testDeepMap(t, tests...)
+}
+
+func TestNestedPointerUnpacking(t *testing.T) {
+	t.Parallel()
+
+	type C struct{ C int }
+	type B struct{ B *C }
+	type A struct{ A *B }
+
+	tests := []test{
+		{
+			name: "nil",
+			dst:  &A{},
+			src:  map[string]any{"a": map[string]any{"b": map[string]any{"c": 1}}},
+			want: &A{&B{&C{1}}},
+		},
+		{
+			name: "partial",
+			dst:  &A{&B{}},
+			src:  map[string]any{"a": map[string]any{"b": map[string]any{"c": 1}}},
+			want: &A{&B{&C{1}}},
+		},
+		{
+			name: "pointer to pointer",
+			dst:  New(&A{}),
+			src:  map[string]any{"a": map[string]any{"b": map[string]any{"c": 1}}},
+			want: New(&A{&B{&C{1}}}),
+		},
+		{
+			name: "struct value",
+			dst:  &A{},
+			src:  map[string]any{"a": map[string]any{"b": C{1}}},
+			want: &A{&B{&C{1}}},
+		},
+	}
+
+	testDeepMap(t, tests...)
+}
+
+func TestMapWithDryRun(t *testing.T) {
+	t.Parallel()
+
+	type Inner struct{ N int }
+	type T struct {
+		A     string
+		B     int
+		Inner *Inner
+		M     map[string]int
+	}
+
+	newDst := func() *T { return &T{A: "foo", M: map[string]int{"a": 1}} }
+	src := map[string]any{"a": "bar", "b": 1, "inner": map[string]any{"n": 2}, "m": map[string]int{"a": 3, "c": 4}}
+
+	var changes []Change
+	dst := newDst()
+	if err := DeepMap(dst, src, WithOverwrite(), WithDryRun(&changes), WithSortedMapKeys()); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := newDst(); !cmp.Equal(want, dst) {
+		t.Errorf("dst was modified: %s", cmp.Diff(want, dst))
+	}
+
+	want := []Change{
+		{".A", "foo", "bar"},
+		{".B", 0, 1},
+		{".Inner", (*Inner)(nil), &Inner{2}},
+		{".M[a]", 1, 3},
+		{".M[c]", nil, 4},
+	}
+	if !cmp.Equal(want, changes) {
+		t.Error(cmp.Diff(want, changes))
+	}
+
+	var pp *T
+	changes = nil
+	if err := DeepMap(&pp, src, WithDryRun(&changes)); err != nil {
+		t.Fatal(err)
+	}
+	if pp != nil {
+		t.Errorf("nil dst was allocated: %#v", pp)
+	}
+	if len(changes) == 0 {
+		t.Error("no changes recorded")
+	}
+}
@@ -10,9 +10,11 @@ package merge
 import (
 	"errors"
 	"fmt"
+	"math"
+	"math/cmplx"
 	"reflect"
 	"runtime"
-	"unsafe"
+	"sync"
 )
 
 // During deepValueMerge, must keep track of checks that are
@@ -20,7 +22,7 @@ import (
 // checks in progress are true when it reencounters them.
 // Visited comparisons are stored in a map indexed by visit.
 type visit struct {
-	a   unsafe.Pointer
+	a   pointer
 	typ reflect.Type
 }
 
@@ -29,10 +31,194 @@ func stack() string {
 	return string(buf[:runtime.Stack(buf[:], false)])
 }
 
+// mergeableTypes reports whether values of type src can be merged into dst.
+// The types must be identical, except that arrays of the same element type
+// may differ in length.
+func mergeableTypes(dst, src reflect.Type) bool {
+	if dst == src {
+		return true
+	}
+	return reflect.Array == dst.Kind() && reflect.Array == src.Kind() &&
+		dst.Elem() == src.Elem()
+}
+
+// isNumeric reports whether k is an integer, floating-point or complex kind.
+func isNumeric(k reflect.Kind) bool {
+	return reflect.Int <= k && k <= reflect.Complex128
+}
+
+// convertibleTypes reports whether values of type src can be converted to dst
+// for merging: the types must be of the same kind, like a named type
+// and its underlying type.
+func convertibleTypes(dst, src reflect.Type) bool {
+	return dst.Kind() == src.Kind() && src.ConvertibleTo(dst)
+}
+
+// typeCheckCompatible reports whether WithTypeCheck lets a src value of type src
+// overwrite a dst value of type dst: the types are identical, or they are of the
+// same kind and each converts to the other, like time.Duration and int64 or
+// a named float64 type and float64.
+func typeCheckCompatible(dst, src reflect.Type) bool {
+	return dst == src || convertibleTypes(dst, src) && convertibleTypes(src, dst)
+}
+
+// growSlice extends the slice dst to length n, reusing its capacity if possible
+// unless WithCopyOnGrow is set. With WithPreserveCap dst grows like it would
+// by append, leaving spare capacity.
+func (c *Config) growSlice(dst reflect.Value, n int) {
+	if n <= dst.Cap() && !c.copyOnGrow {
+		dst.SetLen(n)
+		return
+	}
+
+	if c.preserveCap && n > dst.Cap() && c.allocator == nil {
+		dst.Grow(n - dst.Len())
+		dst.SetLen(n)
+		return
+	}
+	m := n
+	switch {
+	case c.preserveCap && n > dst.Cap():
+		// Leave spare capacity like append, which at least doubles it.
+		if m < 2*dst.Cap() {
+			m = 2 * dst.Cap()
+		}
+	case c.preserveCap:
+		m = dst.Cap()
+	}
+	s := c.makeSlice(dst.Type(), n, m)
+	reflect.Copy(s, dst)
+	dst.Set(s)
+}
+
+// mergeMapValue returns the value to set in the dst map m: the value val1 of the
+// src map srcMap deeply merged into old, the value of m for the same key, which is
+// invalid if m has no such key.
+func (c *Config) mergeMapValue(path string, m, srcMap, old, val1 reflect.Value, visited map[visit]string) (reflect.Value, error) {
+	// A new or nil dst pointer gets a copy of the value src points to,
+	// even with WithoutDereference, so that dst and src do not alias.
+	if et := m.Type().Elem(); reflect.Pointer == et.Kind() && reflect.Pointer == val1.Kind() &&
+		!val1.IsNil() && (!old.IsValid() || old.IsNil()) {
+		p := c.new(et.Elem())
+		if err := deepValueMerge("(*"+path+")", p.Elem(), val1.Elem(), visited, c); err != nil {
+			return reflect.Value{}, err
+		}
+		return p.Convert(et), nil
+	}
+
+	var val2 reflect.Value
+	if !old.IsValid() {
+		val2 = c.new(val1.Type()).Elem()
+		debugf("add map key %s (%#v)\n", path, val1)
+	} else {
+		val2 = c.new(old.Type()).Elem()
+		val2.Set(old)
+	}
+
+	if err := deepValueMerge(path, val2, val1, visited, c.forMapValue(srcMap, val1)); err != nil {
+		return reflect.Value{}, err
+	}
+	return val2, nil
+}
+
+// mapJob is a value of a src map merged by a worker of WithParallelism.
+type mapJob struct {
+	path        string
+	k, old, val reflect.Value
+
+	v   reflect.Value // the merged value, set by the worker
+	err error
+}
+
+// mergeMapValuesParallel merges the values of jobs in c.parallelism goroutines,
+// each with its own copy of visited, and sets the merged values in the dst map m.
+func (c *Config) mergeMapValuesParallel(m, srcMap reflect.Value, jobs []mapJob, visited map[visit]string) error {
+	if len(jobs) == 0 {
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	size := (len(jobs) + c.parallelism - 1) / c.parallelism
+	for i := 0; i < len(jobs); i += size {
+		part := jobs[i:]
+		if len(part) > size {
+			part = part[:size]
+		}
+		visited := cloneMap(visited)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range part {
+				job := &part[j]
+				if job.v, job.err = c.mergeMapValue(job.path, m, srcMap, job.old, job.val, visited); job.err != nil {
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Only this goroutine writes to m.
+	for _, job := range jobs {
+		if job.err != nil {
+			return job.err
+		}
+		if job.v.IsValid() {
+			c.setMapIndex(m, job.k, job.v, job.old)
+		}
+	}
+	return nil
+}
+
+// appendUnique returns s with the elements of t that are not deeply equal
+// to an element of s, or to an earlier element of t, appended.
+func (c *Config) appendUnique(s, t reflect.Value) reflect.Value {
+	// Reserve room for all of t, so that appending never reallocates.
+	r := c.appendSlice(s, t).Slice(0, s.Len())
+	for i := 0; i < t.Len(); i++ {
+		if e := t.Index(i); !containsValue(r, e) {
+			r = reflect.Append(r, e)
+		}
+	}
+	return r
+}
+
+// containsValue reports whether the slice s has an element deeply equal to v.
+func containsValue(s, v reflect.Value) bool {
+	for i := 0; i < s.Len(); i++ {
+		if reflect.DeepEqual(valueInterface(s.Index(i)), valueInterface(v)) {
+			return true
+		}
+	}
+	return false
+}
+
+// prepend returns s with the elements of t inserted at the front.
+// Like reflect.AppendSlice, it returns s unchanged if t is empty.
+func (c *Config) prepend(s, t reflect.Value) reflect.Value {
+	if t.Len() == 0 {
+		return s
+	}
+	r := c.makeSlice(s.Type(), 0, t.Len()+s.Len())
+	return reflect.AppendSlice(reflect.AppendSlice(r, t), s)
+}
+
+// appendSlice is like reflect.AppendSlice but grows s with the allocator, if any.
+func (c *Config) appendSlice(s, t reflect.Value) reflect.Value {
+	n := s.Len() + t.Len()
+	if c.allocator == nil || n <= s.Cap() {
+		return reflect.AppendSlice(s, t)
+	}
+	r := c.makeSlice(s.Type(), s.Len(), n)
+	reflect.Copy(r, s)
+	return reflect.AppendSlice(r, t)
+}
+
 // Merges for deep merge using reflected types. The map argument tracks
 // comparisons that have already been seen, which allows short circuiting on
 // recursive types.
-func deepValueMerge(path string, dst, src reflect.Value, visited map[visit]string, c *Config) error {
+func deepValueMerge(path string, dst, src reflect.Value, visited map[visit]string, c *Config) (err error) {
 	debugf("deepValueMerge %q\n", path)
 
 	if !dst.IsValid() || !src.IsValid() {
@@ -41,8 +227,43 @@ func deepValueMerge(path string, dst, src reflect.Value, visited map[visit]strin
 		}
 		return errors.New("dst.IsValid() != src.IsValid()")
 	}
-	if dst.Type() != src.Type() {
-		return errors.New(dst.Type().String() + " != " + src.Type().String())
+
+	c.enter(path)
+	if len(c.scopes) > 0 {
+		if c, err = c.at(path); err != nil {
+			return err
+		}
+	}
+
+	if !mergeableTypes(dst.Type(), src.Type()) {
+		if c.numericConvert && isNumeric(dst.Kind()) && isNumeric(src.Kind()) {
+			c.trace(path, TraceConvert, dst, src)
+			return deepValueMap(path, dst, src, visited, c)
+		}
+		// Structs of different types are merged field by field, see WithFieldAlias.
+		if c.fieldAliases != nil && reflect.Struct == dst.Kind() && reflect.Struct == src.Kind() {
+			c.trace(path, TraceRecurse, dst, src)
+			return c.mergeStructByName(path, dst, src, visited, deepValueMerge, mergeFieldPath)
+		}
+		if c.typeCheck && !typeCheckCompatible(dst.Type(), src.Type()) || !convertibleTypes(dst.Type(), src.Type()) {
+			return errors.New(dst.Type().String() + " != " + src.Type().String())
+		}
+		c.trace(path, TraceConvert, dst, src)
+		src = src.Convert(dst.Type())
+	}
+
+	if c.beforeHook != nil {
+		c.beforeHook(path, dst, src)
+	}
+	if c.afterHook != nil {
+		defer c.afterHook(path, dst, src)
+	}
+	if fn := c.postProcessors[dst.Type()]; fn != nil {
+		defer func() {
+			if err == nil {
+				err = fn(dst)
+			}
+		}()
 	}
 
 	// We want to avoid putting more in the visited map than we need to.
@@ -65,12 +286,12 @@ func deepValueMerge(path string, dst, src reflect.Value, visited map[visit]strin
 		// which we do by calling the pointer method.
 		// For Slice or Interface, flagIndir is always set,
 		// and using v.ptr suffices.
-		ptrval := func(v reflect.Value) unsafe.Pointer {
+		ptrval := func(v reflect.Value) pointer {
 			switch v.Kind() {
 			case reflect.Pointer, reflect.Map, reflect.Slice:
-				return v.UnsafePointer()
+				return valuePointer(v)
 			default:
-				return v.Addr().UnsafePointer()
+				return valuePointer(v.Addr())
 			}
 		}
 
@@ -84,7 +305,7 @@ func deepValueMerge(path string, dst, src reflect.Value, visited map[visit]strin
 		if visited[v] != "" {
 			debugln("cycle traverses. conflicts are:\nA) " + visited[v] + "\n\nand\nB) " + stack())
 			// shallow merge
-			dst.Set(src)
+			c.set(path, dst, src)
 			return nil
 		}
 
@@ -92,59 +313,109 @@ func deepValueMerge(path string, dst, src reflect.Value, visited map[visit]strin
 		visited[v] = stack()
 	}
 
-	if fn := c.transformers[dst.Type()]; fn.IsValid() {
-		if err, _ := fn.Call([]reflect.Value{dst.Addr(), src})[0].Interface().(error); err != nil {
-			return err
-		}
-		return nil
+	if ok, err := c.transform(path, dst, src); ok {
+		c.trace(path, TraceTransform, dst, src)
+		return err
+	}
+	if ok, err := c.transformPartial(dst, src); ok {
+		c.trace(path, TraceTransform, dst, src)
+		return err
+	}
+	if isOrderedMap(dst, src) {
+		return c.mergeOrderedMap(path, dst, src, visited, deepValueMerge)
+	}
+
+	switch dst.Kind() {
+	case reflect.Array, reflect.Slice, reflect.Interface, reflect.Pointer, reflect.Struct, reflect.Map:
+		c.trace(path, TraceRecurse, dst, src)
 	}
 
 	switch dst.Kind() {
 	case reflect.Array:
-		for i := 0; i < dst.Len(); i++ {
+		for i := 0; i < dst.Len() && i < src.Len(); i++ {
 			if err := deepValueMerge(fmt.Sprintf("%s[%d]", path, i),
 				dst.Index(i), src.Index(i), visited, c); err != nil {
 				return err
 			}
 		}
+
+		// Ensure that all elements in dst are zeroed if src's len shorter than dst.
+		if c.overwriteWithEmptyValue {
+			for i := src.Len(); i < dst.Len(); i++ {
+				c.set(fmt.Sprintf("%s[%d]", path, i), dst.Index(i), reflect.Zero(dst.Type().Elem()))
+			}
+		}
 		return nil
 	case reflect.Slice:
 		if dst.Len() == 0 && (src.Len() == 0 && c.overwriteEmptySlice) {
 			if dst.IsNil() != src.IsNil() {
-				dst.Set(src)
+				c.set(path, dst, src)
 			}
 			return nil
 		}
-		if c.appendSlice {
-			dst.Set(reflect.AppendSlice(dst, src))
+		switch {
+		case c.prependSlice:
+			c.set(path, dst, c.prepend(dst, src))
+			return nil
+		case SliceAppend == c.sliceStrategy:
+			c.set(path, dst, c.appendSlice(dst, src))
+			return nil
+		case SliceAppendUnique == c.sliceStrategy:
+			c.set(path, dst, c.appendUnique(dst, src))
 			return nil
+		case SliceReplace == c.sliceStrategy:
+			if src.Len() == 0 && !c.overwriteWithEmptyValue {
+				return nil
+			}
+			// Merge into a new slice, which makes dst a deep copy of src.
+			c.set(path, dst, reflect.Zero(dst.Type()))
 		}
 
 		if dst.Len() < src.Len() {
-			if src.Len() <= dst.Cap() {
-				dst.Set(dst.Slice(0, src.Len()))
-			} else {
-				s := reflect.MakeSlice(dst.Type(), src.Len(), src.Len())
-				reflect.Copy(s, dst)
-				dst.Set(s)
-			}
+			c.growSlice(dst, src.Len())
 		}
 
-		if dst.UnsafePointer() == src.UnsafePointer() {
+		if valuePointer(dst) == valuePointer(src) {
 			return nil
 		}
 
 		for i := 0; i < dst.Len() && i < src.Len(); i++ {
-			if err := deepValueMerge(fmt.Sprintf("%s[%d]", path, i),
-				dst.Index(i), src.Index(i), visited, c); err != nil {
+			elemPath := fmt.Sprintf("%s[%d]", path, i)
+			dv, sv := dst.Index(i), src.Index(i)
+
+			if ok, err := c.transformElement(elemPath, dst.Type(), dv, sv); ok {
+				c.trace(elemPath, TraceTransform, dv, sv)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+
+			// A nil dst pointer gets a copy of the value src points to,
+			// even with WithoutDereference, so that dst and src do not alias.
+			if reflect.Pointer == dv.Kind() && reflect.Pointer == sv.Kind() && dv.IsNil() && !sv.IsNil() {
+				p := c.new(dv.Type().Elem())
+				if err := deepValueMerge("(*"+elemPath+")", p.Elem(), sv.Elem(), visited, c); err != nil {
+					return err
+				}
+				c.set(elemPath, dv, p)
+				continue
+			}
+
+			if err := deepValueMerge(elemPath, dv, sv, visited, c); err != nil {
 				return err
 			}
 		}
 
+		if SliceTruncate == c.sliceStrategy && dst.Len() > src.Len() {
+			c.set(path, dst, dst.Slice(0, src.Len()))
+			return nil
+		}
+
 		// Ensure that all elements in dst are zeroed if src's len shorter than dst.
-		if c.overwriteWithEmptyValue {
+		if c.overwriteWithEmptyValue && !c.preferLongerSlice {
 			for i := src.Len(); i < dst.Len(); i++ {
-				dst.Index(i).SetZero()
+				c.set(fmt.Sprintf("%s[%d]", path, i), dst.Index(i), reflect.Zero(dst.Type().Elem()))
 			}
 		}
 
@@ -152,7 +423,7 @@ func deepValueMerge(path string, dst, src reflect.Value, visited map[visit]strin
 	case reflect.Interface:
 		if c.shouldNotDereference {
 			if (dst.IsNil() || c.overwrite) && (!src.IsNil() || c.overwriteWithEmptyValue) {
-				dst.Set(src)
+				c.set(path, dst, src)
 			}
 			return nil
 		}
@@ -163,8 +434,8 @@ func deepValueMerge(path string, dst, src reflect.Value, visited map[visit]strin
 
 			if src.IsNil() {
 				// Ensure the value that dst contains is zeroed.
-				if !dst.IsNil() && !dst.Elem().IsZero() && c.overwriteWithEmptyValue {
-					dst.Set(reflect.Zero(dst.Elem().Type()))
+				if !dst.IsNil() && !c.isZero(dst.Elem()) && c.overwriteWithEmptyValue {
+					c.set(path, dst, reflect.Zero(dst.Elem().Type()))
 				}
 				return nil
 			}
@@ -176,15 +447,23 @@ func deepValueMerge(path string, dst, src reflect.Value, visited map[visit]strin
 
 		debugln("path:", path)
 
-		if dst.Elem().Type() != src.Elem().Type() && c.overwrite && !c.appendSlice {
+		if dst.Elem().Type() != src.Elem().Type() && c.overwrite && !c.concatSlices() {
 			if c.typeCheck {
-				return errors.New("overwrite interface value with difference concrete type")
+				return fmt.Errorf("%q: overwrite interface value with difference concrete type %s <- %s", path, dst.Elem().Type(), src.Elem().Type())
+			}
+			if c.preserveDynamicType {
+				v, err := convertDynamicType(src.Elem(), dst.Elem().Type())
+				if err != nil {
+					return fmt.Errorf("%q: %w", path, err)
+				}
+				c.set(path, dst, v)
+				return nil
 			}
-			dst.Set(src.Elem())
+			c.set(path, dst, src.Elem())
 			return nil
 		}
 
-		de := reflect.New(dst.Elem().Type()).Elem()
+		de := c.new(dst.Elem().Type()).Elem()
 		de.Set(dst.Elem())
 		if err := deepValueMerge(fmt.Sprintf("%s(%s)", path, dst.Type()), de, src.Elem(), visited, c); err != nil {
 			return err
@@ -192,42 +471,91 @@ func deepValueMerge(path string, dst, src reflect.Value, visited map[visit]strin
 		dst.Set(de)
 		return nil
 	case reflect.Pointer:
-		if c.shouldNotDereference {
+		// With WithFillNilPointers only nil dst pointers are merged into.
+		if c.fillNilPointers && !dst.IsNil() {
+			c.trace(path, TraceSkip, dst, src)
+			return nil
+		}
+		if c.shouldNotDereference && !c.fillNilPointers {
+			if ok, err := c.transformPointee(path, dst, src); ok {
+				c.trace(path, TraceTransform, dst, src)
+				return err
+			}
 			if (dst.IsNil() || c.overwrite) && (!src.IsNil() || c.overwriteWithEmptyValue) {
-				dst.Set(src)
+				c.set(path, dst, src)
 			}
 			return nil
 		}
 
-		if dst.UnsafePointer() == src.UnsafePointer() {
+		if valuePointer(dst) == valuePointer(src) {
 			return nil
 		}
 
 		if dst.IsNil() != src.IsNil() {
 			if src.IsNil() {
-				if !dst.IsNil() && !dst.Elem().IsZero() && c.overwriteWithEmptyValue {
+				if !dst.IsNil() && !c.isZero(dst.Elem()) && c.overwriteWithEmptyValue {
 					// Ensure the value that dst points to is zeroed.
-					dst.Elem().SetZero()
+					c.set(fmt.Sprintf("(*%s)", path), dst.Elem(), reflect.Zero(dst.Type().Elem()))
 				}
 				return nil
 			}
 			if dst.IsNil() {
-				dst.Set(reflect.New(dst.Type().Elem()))
+				dst.Set(c.new(dst.Type().Elem()))
 			}
 		}
 
 		return deepValueMerge(fmt.Sprintf("(*%s)", path), dst.Elem(), src.Elem(), visited, c)
 	case reflect.Struct:
+		// Values that the custom empty func reports as empty are merged as a whole.
+		if c.emptyFunc != nil {
+			if c.isZero(src) && !c.overwriteWithEmptyValue {
+				return nil
+			}
+			if c.isZero(dst) && !c.isZero(src) {
+				c.set(path, dst, src)
+				return nil
+			}
+		}
+
 		var hasExportedField bool
-		for i, n := 0, dst.NumField(); i < n; i++ {
-			typeOfF := dst.Type().Field(i)
-			if !typeOfF.IsExported() && reflect.Struct != typeOfF.Type.Kind() && !typeOfF.Anonymous {
+		fs := cachedFields(dst.Type())
+		var expose bool
+		src, expose = c.exposeFields(fs, dst, src)
+		fields := fs.list
+		for i := range fields {
+			typeOfF := &fields[i]
+			df, sf := dst.Field(i), src.Field(i)
+			if expose && !typeOfF.IsExported() {
+				df, sf = exposeUnexported(df), exposeUnexported(sf)
+			} else if !typeOfF.mergeable {
 				continue
 			}
 
 			hasExportedField = true
-			filedPath := fmt.Sprintf("%s.%s", path, typeOfF.Name)
-			if err := deepValueMerge(filedPath, dst.Field(i), src.Field(i), visited, c); err != nil {
+			filedPath := path + typeOfF.mergePath
+			if !c.filterField(filedPath, typeOfF.StructField) {
+				c.trace(filedPath, TraceSkip, df, sf)
+				continue
+			}
+			// Empty src values of omitempty fields are never merged, whatever the options.
+			if typeOfF.omitEmpty && c.isZero(sf) {
+				c.trace(filedPath, TraceSkip, df, sf)
+				continue
+			}
+
+			// A nil embedded pointer to an unexported struct type can not be allocated,
+			// so its promoted fields are left alone like encoding/json does.
+			if typeOfF.Anonymous && reflect.Pointer == df.Kind() && df.IsNil() && !df.CanSet() {
+				continue
+			}
+			if ok, err := c.transformField(filedPath, typeOfF, df, sf); ok {
+				c.trace(filedPath, TraceTransform, df, sf)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			if err := deepValueMerge(filedPath, df, sf, visited, c); err != nil {
 				return err
 			}
 		}
@@ -235,70 +563,203 @@ func deepValueMerge(path string, dst, src reflect.Value, visited map[visit]strin
 		if hasExportedField {
 			return nil
 		}
+
+		// Structs with only unexported fields, such as time.Time, are merged
+		// as a whole, which is impossible if dst was reached through an unexported field.
+		if !dst.CanSet() {
+			if c.strictUnexported {
+				return fmt.Errorf("%q: cannot merge %s with only unexported fields into an unexported field", path, dst.Type())
+			}
+			return nil
+		}
 	case reflect.Map:
 		if dst.IsNil() != src.IsNil() {
-			if dst.IsNil() && src.Len() > 0 {
-				dst.Set(reflect.MakeMapWithSize(dst.Type(), src.Len()))
+			if dst.IsNil() && (src.Len() > 0 || c.initEmptyMaps) {
+				dst.Set(c.makeMap(dst.Type(), src.Len()))
 			}
 		}
-		if dst.UnsafePointer() == src.UnsafePointer() {
+		if valuePointer(dst) == valuePointer(src) {
 			return nil
 		}
-		for it := src.MapRange(); it.Next(); {
-			k := it.Key()
+		if err := c.normalizeMapKeys(path, dst, visited, deepValueMerge); err != nil {
+			return err
+		}
+
+		var jobs []mapJob
+		parallel := "" == path && c.mergeMapInParallel(src.Len())
+		for it := c.mapRange(src); it.Next(); {
+			k, err := c.transformKey(path, dst, it.Key())
+			if err != nil {
+				return err
+			}
 			val1 := it.Value()
-			val2 := dst.MapIndex(k)
+			old := dst.MapIndex(k)
 
 			if !val1.IsValid() {
 				continue
 			}
 
-			if !val2.IsValid() {
-				v := reflect.New(val1.Type()).Elem()
-				v.SetZero()
-				val2 = v
-				debugf("add map key (%#v, %#v)\n", k, val1)
-			} else {
-				val := reflect.New(val2.Type()).Elem()
-				val.Set(val2)
-				val2 = val
+			elemPath := fmt.Sprintf("%s[%v]", path, k)
+			if ok, err := c.resolveMapConflict(elemPath, dst, k, old, val1); ok {
+				if err != nil {
+					return err
+				}
+				continue
 			}
 
-			if err := deepValueMerge(fmt.Sprintf("%s[%s]", path,
-				k.String()), val2, val1, visited, c); err != nil {
+			if v, ok := c.appendMapSlice(old, val1); ok {
+				c.setMapValue(elemPath, dst, k, v, old)
+				continue
+			}
+
+			// A new key is assigned as a whole.
+			if !old.IsValid() && !c.assignOnce(elemPath) {
+				continue
+			}
+
+			if parallel {
+				jobs = append(jobs, mapJob{path: elemPath, k: k, old: old, val: val1})
+				continue
+			}
+			v, err := c.mergeMapValue(elemPath, dst, src, old, val1, visited)
+			if err != nil {
 				return err
 			}
-			dst.SetMapIndex(k, val2)
+			c.setMapIndex(dst, k, v, old)
+		}
+		if err := c.mergeMapValuesParallel(dst, src, jobs, visited); err != nil {
+			return err
 		}
 
 		// Ensure that all keys in dst are deleted if they are not present in src.
-		if c.overwriteWithEmptyValue {
-			for it := dst.MapRange(); it.Next(); {
+		if c.pruneMissingKeys {
+			for it := c.mapRange(dst); it.Next(); {
 				k := it.Key()
-				if !src.MapIndex(k).IsValid() {
-					dst.SetMapIndex(k, reflect.Value{})
+				if !c.srcHasKey(src, k) {
+					c.deleteMapIndex(fmt.Sprintf("%s[%v]", path, k), dst, k)
 				}
 			}
 		}
 		return nil
+	case reflect.Chan:
+		if ChannelOverwriteNonNil == c.channelPolicy {
+			if dst.IsNil() && !src.IsNil() {
+				c.set(path, dst, src)
+			}
+			return nil
+		}
+	case reflect.String:
+		if c.concatStrings {
+			if c.isZero(src) {
+				return nil
+			}
+			if c.isZero(dst) {
+				c.set(path, dst, src)
+			} else {
+				c.set(path, dst, reflect.ValueOf(dst.String()+c.stringSep+src.String()).Convert(dst.Type()))
+			}
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if r := c.reducer(path, dst.Type()); r != 0 {
+			x, y := dst.Int(), src.Int()
+			v := y
+			switch {
+			case ReduceSum == r:
+				v = x + y
+				if (y > 0 && v < x) || (y < 0 && v > x) || dst.OverflowInt(v) {
+					return fmt.Errorf("%d + %d overflow %s", x, y, dst.Type())
+				}
+			case c.isZero(dst):
+			case ReduceMax == r && x > y, ReduceMin == r && x < y:
+				v = x
+			}
+			c.set(path, dst, reflect.ValueOf(v).Convert(dst.Type()))
+			return nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if r := c.reducer(path, dst.Type()); r != 0 {
+			x, y := dst.Uint(), src.Uint()
+			v := y
+			switch {
+			case ReduceSum == r:
+				v = x + y
+				if v < x || dst.OverflowUint(v) {
+					return fmt.Errorf("%d + %d overflow %s", x, y, dst.Type())
+				}
+			case c.isZero(dst):
+			case ReduceMax == r && x > y, ReduceMin == r && x < y:
+				v = x
+			}
+			c.set(path, dst, reflect.ValueOf(v).Convert(dst.Type()))
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		if nan, err := c.checkNaN(path, src); nan {
+			return err
+		}
+		if r := c.reducer(path, dst.Type()); r != 0 {
+			x, y := dst.Float(), src.Float()
+			v := y
+			switch {
+			case ReduceSum == r:
+				v = x + y
+				if (math.IsInf(v, 0) && !math.IsInf(x, 0) && !math.IsInf(y, 0)) || dst.OverflowFloat(v) {
+					return fmt.Errorf("%g + %g overflow %s", x, y, dst.Type())
+				}
+			case c.isZero(dst):
+			case ReduceMax == r && x > y, ReduceMin == r && x < y:
+				v = x
+			}
+			c.set(path, dst, reflect.ValueOf(c.roundFloat(v)).Convert(dst.Type()))
+			return nil
+		}
+		if !c.isZero(dst) && c.shouldSet(dst, src) && c.withinFloatTolerance(dst, src.Float()) {
+			c.trace(path, TraceSkip, dst, src)
+			return nil
+		}
+	case reflect.Complex64, reflect.Complex128:
+		if r := c.reducer(path, dst.Type()); r != 0 {
+			if ReduceSum != r {
+				return fmt.Errorf("%q: complex numbers are not ordered", path)
+			}
+
+			x, y := dst.Complex(), src.Complex()
+			v := x + y
+			if (cmplx.IsInf(v) && !cmplx.IsInf(x) && !cmplx.IsInf(y)) || dst.OverflowComplex(v) {
+				return fmt.Errorf("%g + %g overflow %s", x, y, dst.Type())
+			}
+			c.set(path, dst, reflect.ValueOf(v).Convert(dst.Type()))
+			return nil
+		}
 	default:
 	}
 
 	// Normal merge suffices
-	if (dst.IsZero() || c.overwrite) && (!src.IsZero() || c.overwriteWithEmptyValue) {
+	if c.shouldSet(dst, src) {
 		debugf("%q %#v <- %#v\n", path, dst, src)
-		dst.Set(src)
+		if c.roundFloats && src.CanFloat() {
+			src = reflect.ValueOf(c.roundFloat(src.Float())).Convert(src.Type())
+		}
+		c.set(path, dst, src)
+	} else {
+		c.trace(path, TraceSkip, dst, src)
 	}
 	return nil
 }
 
 // DeepMerge "deeply merge," the contents of src into dst defined as follows.
 // Two values of identical type can deeply merge it following cases applies.
-// Values of distinct types can not deeply merge.
+// Values of distinct types can not deeply merge, except that a value of a type
+// of the same kind and convertible to dst's type, like float64 for
+// type Celsius float64, is converted first unless WithTypeCheck is set.
 //
-// Array values deeply merge their corresponding elements.
+// Array values deeply merge their corresponding elements (up to length).
+// Arrays of the same element type but differing length can deeply merge.
 //
 // Struct values deeply merge their corresponding exported fields.
+// Fields tagged `merge:"omitempty"` are left alone when the src field is empty,
+// even with WithOverwrite or WithOverwriteWithEmptyValue.
 //
 // Func values deeply merge if dst is nil and src is not; otherwise they not deeply merge.
 //
@@ -314,6 +775,9 @@ func deepValueMerge(path string, dst, src reflect.Value, visited map[visit]strin
 // Slice values deeply merge when all of the following are true:
 // either they point to the same initial entry of the same underlying array
 // (that is, &x[0] == &y[0]) or their corresponding elements (up to length) deeply merged.
+// Each element follows the rules of its own type, so in a slice of pointers
+// a nil dst element is allocated for a non-nil src element, and a nil src
+// element leaves the dst element alone.
 //
 // Other values - numbers, bools, strings, and channels - deeply merge
 // if dst is zero value and src is not, they deeply merged dst = src using Go's = operator.
@@ -329,12 +793,20 @@ func deepValueMerge(path string, dst, src reflect.Value, visited map[visit]strin
 // second and subsequent times that DeepMerge compares two pointer
 // values that have been merged before, it treats the values as
 // merged rather than examining the values to which they point.
+// The same applies to maps and slices, including maps that contain
+// themselves through interface values.
 // This ensures that DeepMerge terminates.
-func DeepMerge(dst, src any, opts ...Option) error {
+func DeepMerge(dst, src any, opts ...Option) (err error) {
 	debugf("Merge %#v %[1]T\n", dst)
 
-	if dst == nil || src == nil {
-		return errors.New("dst or src is nil")
+	var c Config
+	Options(opts).apply(&c)
+	if err := c.validate(); err != nil {
+		return err
+	}
+	defer c.recoverPanic(&err)
+	if noop, err := c.checkNil(dst, src); noop || err != nil {
+		return err
 	}
 
 	vdst := reflect.ValueOf(dst)
@@ -356,9 +828,12 @@ func DeepMerge(dst, src any, opts ...Option) error {
 		vdst = vdst.Elem()
 		if reflect.Pointer == vdst.Kind() {
 			if vdst.IsNil() {
-				p := reflect.New(vdst.Type().Elem())
-				debugf("SetPointer %s %p", p.Elem().Type(), p.UnsafePointer())
-				vdst.Set(p)
+				p := c.new(vdst.Type().Elem())
+				debugf("SetPointer %s %p", p.Elem().Type(), p.Interface())
+				if !c.dryRun {
+					vdst.Set(p)
+				}
+				vdst = p
 			}
 			vdst = vdst.Elem()
 		}
@@ -368,12 +843,121 @@ func DeepMerge(dst, src any, opts ...Option) error {
 		vsrc = vsrc.Elem()
 	}
 
+	// Merge into the struct that an interface holds a pointer to, as for
+	// DeepMerge(&v, T{}) where v is an any holding a *T.
 	if vdst.Type() != vsrc.Type() {
-		return errors.New(vdst.Type().String() + " != " + vsrc.Type().String())
+		vdst, vsrc = unwrapInterface(vdst, true), unwrapInterface(vsrc, false)
+	}
+
+	return c.merge(vdst, vsrc)
+}
+
+// unwrapInterface returns the value that the interface v holds, following
+// pointers. If settable is true, only values held through pointers are returned.
+func unwrapInterface(v reflect.Value, settable bool) reflect.Value {
+	for reflect.Interface == v.Kind() && !v.IsNil() {
+		e := v.Elem()
+		switch {
+		case reflect.Pointer == e.Kind() && !e.IsNil():
+			v = e.Elem()
+		case !settable:
+			v = e
+		default:
+			return v
+		}
+	}
+	return v
+}
+
+// MergeValue is like DeepMerge for reflected values: it deeply merges src into dst,
+// which must be addressable and settable, e.g. reflect.ValueOf(&v).Elem().
+func MergeValue(dst, src reflect.Value, opts ...Option) (err error) {
+	if !dst.IsValid() || !src.IsValid() {
+		return errors.New("dst or src is invalid")
+	}
+	if !dst.CanSet() {
+		return errors.New("dst must be addressable and settable")
+	}
+
+	var c Config
+	Options(opts).apply(&c)
+	if err := c.validate(); err != nil {
+		return err
+	}
+	defer c.recoverPanic(&err)
+	return c.merge(dst, src)
+}
+
+// merge deeply merges vsrc into vdst for DeepMerge and MergeValue.
+func (c *Config) merge(vdst, vsrc reflect.Value) error {
+	// A concrete src merges into an interface dst like interface values do,
+	// as for DeepMerge(new(any), 42).
+	if reflect.Interface == vdst.Kind() && reflect.Interface != vsrc.Kind() && vsrc.Type().Implements(vdst.Type()) {
+		i := reflect.New(vdst.Type()).Elem()
+		i.Set(vsrc)
+		vsrc = i
+	}
+
+	if !mergeableTypes(vdst.Type(), vsrc.Type()) {
+		switch {
+		case c.numericConvert && isNumeric(vdst.Kind()) && isNumeric(vsrc.Kind()),
+			c.fieldAliases != nil && reflect.Struct == vdst.Kind() && reflect.Struct == vsrc.Kind():
+			// Converted by deepValueMerge.
+		case c.typeCheck && !typeCheckCompatible(vdst.Type(), vsrc.Type()) || !convertibleTypes(vdst.Type(), vsrc.Type()):
+			return errors.New(vdst.Type().String() + " != " + vsrc.Type().String())
+		default:
+			vsrc = vsrc.Convert(vdst.Type())
+		}
+	}
+
+	if c.onceSet != nil {
+		defer c.commitOnceSet()
+	}
+
+	// A dry run merges into a copy of dst.
+	if c.dryRun {
+		cp := reflect.New(vdst.Type()).Elem()
+		cp.Set(c.copyOf(vdst))
+		vdst = cp
+	}
+
+	if err := deepValueMerge("", vdst, vsrc, make(map[visit]string), c); err != nil {
+		return err
+	}
+	if c.assertIdempotent {
+		return c.checkIdempotent(vdst, vsrc, deepValueMerge)
+	}
+	return nil
+}
+
+// convertDynamicType converts v to type t for WithPreserveDstDynamicType.
+// Integers are not converted to strings, which would yield a rune rather than digits.
+func convertDynamicType(v reflect.Value, t reflect.Type) (reflect.Value, error) {
+	if !v.CanConvert(t) || (reflect.String == t.Kind() && reflect.String != v.Kind() && !isBytesOrRunes(v.Type())) {
+		return reflect.Value{}, fmt.Errorf("cannot convert %s to dst dynamic type %s", v.Type(), t)
+	}
+	return v.Convert(t), nil
+}
+
+func isBytesOrRunes(t reflect.Type) bool {
+	return reflect.Slice == t.Kind() && (reflect.Uint8 == t.Elem().Kind() || reflect.Int32 == t.Elem().Kind())
+}
+
+// DeepMergeNew is like DeepMerge but merges src into a deep copy of dst,
+// leaving dst untouched. The returned value has the same dynamic type as dst.
+func DeepMergeNew(dst, src any, opts ...Option) (any, error) {
+	if dst == nil || src == nil {
+		return nil, errors.New("dst or src is nil")
 	}
 
 	var c Config
 	Options(opts).apply(&c)
 
-	return deepValueMerge("", vdst, vsrc, make(map[visit]string), &c)
+	vdst := reflect.ValueOf(dst)
+	p := reflect.New(vdst.Type())
+	p.Elem().Set(c.copyOf(vdst))
+	if err := DeepMerge(p.Interface(), src, opts...); err != nil {
+		return nil, err
+	}
+	return p.Elem().Interface(), nil
 }
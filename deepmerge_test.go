@@ -1,7 +1,12 @@
 package merge_test
 
 import (
+	"database/sql"
+	"fmt"
+	"math"
 	"reflect"
+	"runtime"
+	"strconv"
 	"testing"
 	"time"
 
@@ -102,6 +107,17 @@ func testDeepMerge(t *testing.T, tests ...test) {
 	}
 }
 
+// mergeFunc is DeepMerge or DeepMap.
+type mergeFunc func(dst, src any, opts ...Option) error
+
+// testMergeFuncs runs f in a Merge subtest with DeepMerge and in a Map subtest with DeepMap.
+func testMergeFuncs(t *testing.T, f func(t *testing.T, merge mergeFunc)) {
+	t.Helper()
+
+	t.Run("Merge", func(t *testing.T) { f(t, DeepMerge) })
+	t.Run("Map", func(t *testing.T) { f(t, DeepMap) })
+}
+
 func TestBasicTypes(t *testing.T) {
 	t.Parallel()
 
@@ -273,14 +289,215 @@ func TestInterfaces(t *testing.T) {
 	t.Run("Map", func(t *testing.T) { testDeepMap(t, test) })
 }
 
+func TestConcreteIntoInterface(t *testing.T) {
+	t.Parallel()
+
+	type S struct{ A, B int }
+
+	tests := []test{
+		{name: "nil", dst: new(any), src: 42, want: New(any(42))},
+		{name: "keep", dst: New(any(1)), src: 42, want: New(any(1))},
+		{name: "overwrite", dst: New(any(1)), src: 42, mergeOpts: Options{WithOverwrite()}, want: New(any(42))},
+		{name: "struct", dst: New(any(S{A: 1})), src: S{A: 3, B: 2}, want: New(any(S{A: 1, B: 2}))},
+		{
+			name: "map",
+			dst:  New(any(map[string]int{"a": 1})),
+			src:  map[string]int{"b": 2},
+			want: New(any(map[string]int{"a": 1, "b": 2})),
+		},
+	}
+
+	t.Run("Merge", func(t *testing.T) { testDeepMerge(t, tests...) })
+
+	t.Run("Map", func(t *testing.T) {
+		type T struct{ X any }
+		testDeepMap(t, append(tests, test{
+			name: "field",
+			dst:  &T{X: map[string]int{"a": 1}},
+			src:  map[string]any{"X": map[string]int{"b": 2}},
+			want: &T{X: map[string]int{"a": 1, "b": 2}},
+		})...)
+	})
+}
+
+func TestInterfaceHoldingPointer(t *testing.T) {
+	t.Parallel()
+
+	type S struct{ A, B int }
+
+	tests := func() []test {
+		one, two := 1, 2
+		var ifaceInt, srcInt any = &one, &two
+		zero := 0
+		var ifaceZero any = &zero
+
+		s := &S{A: 1}
+		var ifaceS, srcS any = s, &S{A: 3, B: 2}
+
+		return []test{
+			{dst: &ifaceZero, src: &srcInt, want: New(any(New(2))), check: func(t testing.TB, dst any) {
+				if *dst.(*any) != any(&zero) {
+					t.Errorf("interface pointer replaced, want pointee merged")
+				}
+			}},
+			{dst: &ifaceInt, src: &srcInt, want: New(any(New(1)))},
+			{dst: &ifaceS, src: &srcS, want: New(any(&S{A: 1, B: 2})), check: func(t testing.TB, dst any) {
+				if *dst.(*any) != any(s) {
+					t.Errorf("interface pointer replaced, want pointee merged")
+				}
+			}},
+		}
+	}
+
+	t.Run("Merge", func(t *testing.T) { testDeepMerge(t, tests()...) })
+
+	t.Run("Map", func(t *testing.T) { testDeepMap(t, tests()...) })
+}
+
+type name struct{ S string }
+
+func (n name) String() string { return n.S }
+
+func TestNilInterfaceField(t *testing.T) {
+	t.Parallel()
+
+	type T struct{ X fmt.Stringer }
+
+	tests := []test{
+		{
+			name: "value",
+			dst:  &T{},
+			src:  T{X: name{"foo"}},
+			want: &T{X: name{"foo"}},
+		},
+		{
+			name: "pointer",
+			dst:  &T{},
+			src:  T{X: &name{"foo"}},
+			want: &T{X: &name{"foo"}},
+		},
+		{
+			name: "nil src",
+			dst:  &T{},
+			src:  T{},
+			want: &T{},
+		},
+	}
+
+	for _, mode := range []struct {
+		name string
+		opts Options
+	}{
+		{"", nil},
+		{"WithoutDereference", Options{WithoutDereference()}},
+	} {
+		for i := range tests {
+			tests[i].mergeOpts = mode.opts
+		}
+
+		t.Run("Merge"+mode.name, func(t *testing.T) { testDeepMerge(t, tests...) })
+
+		t.Run("Map"+mode.name, func(t *testing.T) {
+			testDeepMap(t, tests...)
+			testDeepMap(t, test{
+				name:      "from map",
+				dst:       &T{},
+				src:       map[string]any{"X": name{"foo"}},
+				mergeOpts: mode.opts,
+				want:      &T{X: name{"foo"}},
+			})
+		})
+	}
+}
+
+// orderedMap is an OrderedMap that keeps its keys in insertion order.
+type orderedMap struct {
+	keys []string
+	m    map[string]point
+}
+
+type point struct{ X, Y int }
+
+func newOrderedMap(kvs ...any) *orderedMap {
+	om := new(orderedMap)
+	for i := 0; i < len(kvs); i += 2 {
+		om.Set(kvs[i].(string), kvs[i+1].(point))
+	}
+	return om
+}
+
+func (om *orderedMap) Keys() []string { return om.keys }
+
+func (om *orderedMap) Get(k string) (point, bool) {
+	v, ok := om.m[k]
+	return v, ok
+}
+
+func (om *orderedMap) Set(k string, v point) {
+	if om.m == nil {
+		om.m = make(map[string]point)
+	}
+	if _, ok := om.m[k]; !ok {
+		om.keys = append(om.keys, k)
+	}
+	om.m[k] = v
+}
+
+var _ OrderedMap[string, point] = (*orderedMap)(nil)
+
+func TestMergeOrderedMap(t *testing.T) {
+	t.Parallel()
+
+	type T struct {
+		M  orderedMap
+		PM *orderedMap
+	}
+
+	tests := func() []test {
+		return []test{
+			{
+				name: "merge",
+				dst:  newOrderedMap("b", point{X: 1}, "a", point{X: 2, Y: 2}),
+				src:  newOrderedMap("c", point{X: 3}, "a", point{X: 4}, "b", point{Y: 5}),
+				want: newOrderedMap("b", point{X: 1, Y: 5}, "a", point{X: 2, Y: 2}, "c", point{X: 3}),
+			},
+			{
+				name:      "overwrite",
+				dst:       newOrderedMap("a", point{X: 1, Y: 1}),
+				src:       newOrderedMap("a", point{X: 2}, "b", point{Y: 3}),
+				mergeOpts: Options{WithOverwrite()},
+				want:      newOrderedMap("a", point{X: 2, Y: 1}, "b", point{Y: 3}),
+			},
+			{
+				name: "fields",
+				dst:  &T{M: *newOrderedMap("a", point{X: 1})},
+				src:  T{M: *newOrderedMap("a", point{Y: 2}), PM: newOrderedMap("b", point{X: 3})},
+				want: &T{M: *newOrderedMap("a", point{X: 1, Y: 2}), PM: newOrderedMap("b", point{X: 3})},
+			},
+		}
+	}
+	cmpOpts := cmp.Options{cmp.AllowUnexported(orderedMap{})}
+
+	withCmpOpts := func(tests []test) []test {
+		for i := range tests {
+			tests[i].cmpOpts = cmpOpts
+		}
+		return tests
+	}
+
+	t.Run("Merge", func(t *testing.T) { testDeepMerge(t, withCmpOpts(tests())...) })
+
+	t.Run("Map", func(t *testing.T) { testDeepMap(t, withCmpOpts(tests())...) })
+}
+
 func TestMergeErrors(t *testing.T) {
 	t.Parallel()
 
 	type mystring string
 
 	tests := []test{
-		{dst: New(0), src: 1.0, wantErr: true, want: New(1)},                            // different types
-		{dst: New(mystring("")), src: "foo", wantErr: true, want: New(mystring("foo"))}, // different types
+		{dst: New(0), src: 1.0, wantErr: true, want: New(1)},             // different types
+		{dst: New(mystring("")), src: "foo", want: New(mystring("foo"))}, // convertible types
 		{wantErr: true}, // all zero values
 	}
 
@@ -334,3 +551,1432 @@ func TestCycles(t *testing.T) {
 
 	t.Run("Map", func(t *testing.T) { testDeepMap(t, tests...) })
 }
+
+// TestCyclicDst checks that merging a finite src into a cyclic dst terminates:
+// merging recurses along src, so cycles in dst alone are never followed.
+func TestCyclicDst(t *testing.T) {
+	t.Parallel()
+
+	type Node struct {
+		V    int
+		Next *Node
+	}
+
+	newSlice := func() []any {
+		s := []any{nil, 1}
+		s[0] = s
+		return s
+	}
+	newNode := func() *Node {
+		n := &Node{}
+		n.Next = n
+		return n
+	}
+	newMap := func() map[string]any {
+		m := map[string]any{"x": 1}
+		m["self"] = m
+		return m
+	}
+
+	for _, opts := range []Options{nil, {WithOverwrite()}, {WithOverwriteWithEmptyValue(), WithPruneMissingKeys()}} {
+		s := newSlice()
+		if err := DeepMerge(&s, []any{[]any{[]any{nil, 2}, 4}, 3}, opts...); err != nil {
+			t.Fatal(err)
+		}
+
+		n := newNode()
+		if err := DeepMerge(n, Node{V: 1, Next: &Node{V: 2, Next: &Node{V: 3}}}, opts...); err != nil {
+			t.Fatal(err)
+		}
+		if opts == nil && n.Next != n {
+			t.Errorf("dst cycle was broken")
+		}
+
+		m := newMap()
+		if err := DeepMerge(&m, map[string]any{"self": map[string]any{"self": map[string]any{"x": 2}}}, opts...); err != nil {
+			t.Fatal(err)
+		}
+
+		s = newSlice()
+		if err := DeepMap(&s, []any{[]any{[]any{nil, 2}, 4}, 3}, opts...); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestArrayPointers(t *testing.T) {
+	t.Parallel()
+
+	type S struct {
+		A *[3]int
+	}
+
+	src := S{A: &[3]int{1, 2, 3}}
+	noAlias := func(t testing.TB, dst any) {
+		if dst.(*S).A == src.A {
+			t.Error("dst array aliases src array")
+		}
+	}
+
+	tests := []test{
+		{
+			name:  "nil dst",
+			dst:   &S{},
+			src:   src,
+			want:  &S{A: &[3]int{1, 2, 3}},
+			check: noAlias,
+		},
+		{
+			name:  "non-nil dst",
+			dst:   &S{A: &[3]int{4, 0, 6}},
+			src:   src,
+			want:  &S{A: &[3]int{4, 2, 6}},
+			check: noAlias,
+		},
+		{
+			name:      "non-nil dst with overwrite",
+			dst:       &S{A: &[3]int{4, 0, 6}},
+			src:       src,
+			mergeOpts: Options{WithOverwrite()},
+			want:      &S{A: &[3]int{1, 2, 3}},
+			check:     noAlias,
+		},
+	}
+
+	t.Run("Merge", func(t *testing.T) { testDeepMerge(t, tests...) })
+
+	t.Run("Map", func(t *testing.T) { testDeepMap(t, tests...) })
+}
+
+func TestArraysOfDifferingLength(t *testing.T) {
+	t.Parallel()
+
+	tests := []test{
+		{
+			dst:  New([...]int{0, 0, 3, 4, 5}),
+			src:  [...]int{1, 2, 0},
+			want: New([...]int{1, 2, 3, 4, 5}),
+		},
+		{
+			dst:  New([...]int{0, 2, 3}),
+			src:  [...]int{1, 0, 0, 4, 5},
+			want: New([...]int{1, 2, 3}),
+		},
+		{
+			dst:       New([...]int{0, 2, 3, 4, 5}),
+			src:       [...]int{1, 0, 3},
+			mergeOpts: Options{WithOverwriteWithEmptyValue()},
+			want:      New([...]int{1, 0, 3, 0, 0}),
+		},
+	}
+
+	t.Run("Merge", func(t *testing.T) { testDeepMerge(t, tests...) })
+}
+
+func TestMergeWithEmptyFunc(t *testing.T) {
+	t.Parallel()
+
+	type T struct {
+		Name sql.NullString
+		Age  int
+	}
+
+	isEmpty := func(v reflect.Value) bool {
+		if ns, ok := v.Interface().(sql.NullString); ok {
+			return !ns.Valid
+		}
+		return v.IsZero()
+	}
+
+	tests := []test{
+		{
+			name:      "invalid dst is empty",
+			dst:       &T{Name: sql.NullString{String: "stale"}},
+			src:       T{Name: sql.NullString{String: "foo", Valid: true}, Age: 1},
+			mergeOpts: Options{WithEmptyFunc(isEmpty)},
+			want:      &T{Name: sql.NullString{String: "foo", Valid: true}, Age: 1},
+		},
+		{
+			name:      "invalid src is empty",
+			dst:       &T{Name: sql.NullString{String: "foo", Valid: true}},
+			src:       T{Name: sql.NullString{String: "bar"}},
+			mergeOpts: Options{WithOverwrite(), WithEmptyFunc(isEmpty)},
+			want:      &T{Name: sql.NullString{String: "foo", Valid: true}},
+		},
+	}
+
+	testDeepMerge(t, tests...)
+}
+
+func TestMergeWithStringConcat(t *testing.T) {
+	t.Parallel()
+
+	type T struct {
+		Prefix string
+		ID     string
+		N      int
+	}
+
+	tests := []test{
+		{
+			dst:       &T{Prefix: "app", N: 1},
+			src:       T{Prefix: "db", ID: "42", N: 2},
+			mergeOpts: Options{WithStringConcat(":")},
+			want:      &T{Prefix: "app:db", ID: "42", N: 1},
+		},
+		{
+			dst:       &T{Prefix: "app", ID: "42", N: 1},
+			src:       T{Prefix: "db", N: 2},
+			mergeOpts: Options{WithStringConcat("/"), WithOverwriteWithEmptyValue()},
+			want:      &T{Prefix: "app/db", ID: "42", N: 2},
+		},
+		{
+			dst:       New(map[string]string{"a": "x"}),
+			src:       map[string]string{"a": "y", "b": "z"},
+			mergeOpts: Options{WithStringConcat(",")},
+			want:      New(map[string]string{"a": "x,y", "b": "z"}),
+		},
+	}
+
+	testDeepMerge(t, tests...)
+}
+
+func TestMergeWithNumericAdd(t *testing.T) {
+	t.Parallel()
+
+	type T struct {
+		I int
+		U uint8
+		F float32
+		C complex128
+	}
+
+	tests := []test{
+		{
+			dst:       &T{1, 2, 1.5, 1 + 1i},
+			src:       T{2, 3, 0.25, 2 - 3i},
+			mergeOpts: Options{WithNumericAdd()},
+			want:      &T{3, 5, 1.75, 3 - 2i},
+		},
+		{
+			dst:       New(map[string]int{"a": 1}),
+			src:       map[string]int{"a": 2, "b": 3},
+			mergeOpts: Options{WithNumericAdd()},
+			want:      New(map[string]int{"a": 3, "b": 3}),
+		},
+		{
+			name:      "int overflow",
+			dst:       New(math.MaxInt64),
+			src:       1,
+			mergeOpts: Options{WithNumericAdd()},
+			wantErr:   true,
+		},
+		{
+			name:      "uint8 overflow",
+			dst:       &T{U: 200},
+			src:       T{U: 100},
+			mergeOpts: Options{WithNumericAdd()},
+			wantErr:   true,
+		},
+		{
+			name:      "float32 overflow",
+			dst:       &T{F: math.MaxFloat32},
+			src:       T{F: math.MaxFloat32},
+			mergeOpts: Options{WithNumericAdd()},
+			wantErr:   true,
+		},
+	}
+
+	testDeepMerge(t, tests...)
+}
+
+func TestMergeSliceWithPrependSlice(t *testing.T) {
+	t.Parallel()
+
+	tests := []test{
+		{
+			dst:       &[]int{1, 2, 3},
+			src:       []int{4, 5},
+			mergeOpts: Options{WithPrependSlice()},
+			want:      &[]int{4, 5, 1, 2, 3},
+		},
+		{
+			dst:       New([]int(nil)),
+			src:       []int{4, 5},
+			mergeOpts: Options{WithPrependSlice()},
+			want:      &[]int{4, 5},
+		},
+		{
+			dst:       &[]int{},
+			src:       []int{4, 5},
+			mergeOpts: Options{WithPrependSlice()},
+			want:      &[]int{4, 5},
+		},
+		{
+			dst:       &[]int{1, 2, 3},
+			src:       []int{},
+			mergeOpts: Options{WithPrependSlice()},
+			want:      &[]int{1, 2, 3},
+		},
+		{
+			dst:       &[]int{1, 2, 3},
+			src:       []int(nil),
+			mergeOpts: Options{WithPrependSlice()},
+			want:      &[]int{1, 2, 3},
+		},
+		{
+			dst:       &[]int{},
+			src:       []int{},
+			mergeOpts: Options{WithPrependSlice()},
+			want:      &[]int{},
+		},
+		{
+			dst:       New([]int(nil)),
+			src:       []int{},
+			mergeOpts: Options{WithPrependSlice()},
+			want:      New([]int(nil)),
+		},
+		{
+			dst:       &[]int{1},
+			src:       []int{2},
+			mergeOpts: Options{WithAppendSlice(), WithPrependSlice()},
+			wantErr:   true,
+		},
+	}
+
+	t.Run("Merge", func(t *testing.T) { testDeepMerge(t, tests...) })
+
+	t.Run("Map", func(t *testing.T) { testDeepMap(t, tests...) })
+
+	t.Run("Map conversion", func(t *testing.T) {
+		tests := []test{
+			{
+				dst:       &[]byte{'c'},
+				src:       "ab",
+				mergeOpts: Options{WithPrependSlice()},
+				want:      &[]byte{'a', 'b', 'c'},
+			},
+			{
+				dst:       &[]int64{3},
+				src:       []int32{1, 2},
+				mergeOpts: Options{WithPrependSlice()},
+				want:      &[]int64{1, 2, 3},
+			},
+		}
+		testDeepMap(t, tests...)
+	})
+}
+
+func TestDeepMergeNew(t *testing.T) {
+	t.Parallel()
+
+	type T struct {
+		A int
+		S []string
+		M map[string]*int
+		P *T
+	}
+
+	dst := &T{S: []string{"", "b"}, M: map[string]*int{"a": New(0)}, P: &T{}}
+	orig := &T{S: []string{"", "b"}, M: map[string]*int{"a": New(0)}, P: &T{}}
+	src := T{A: 1, S: []string{"a", "c"}, M: map[string]*int{"a": New(1), "b": New(2)}, P: &T{A: 3}}
+
+	got, err := DeepMergeNew(dst, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &T{A: 1, S: []string{"a", "b"}, M: map[string]*int{"a": New(1), "b": New(2)}, P: &T{A: 3}}
+	if diff := cmp.Diff(want, got.(*T)); diff != "" {
+		t.Error(diff)
+	}
+	if diff := cmp.Diff(orig, dst); diff != "" {
+		t.Errorf("dst was modified: %s", diff)
+	}
+
+	m, err := DeepMergeNew(map[string]int{"a": 1}, map[string]int{"b": 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]int{"a": 1, "b": 2}; !cmp.Equal(want, m) {
+		t.Error(cmp.Diff(want, m))
+	}
+
+	v, err := DeepMergeNew(T{}, T{A: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (T{A: 1}); !cmp.Equal(want, v) {
+		t.Error(cmp.Diff(want, v))
+	}
+}
+
+func TestSelfReferentialMaps(t *testing.T) {
+	t.Parallel()
+
+	self := map[string]any{"a": 1}
+	self["self"] = self
+
+	nested := map[string]any{"a": 1, "child": map[string]any{}}
+	nested["child"].(map[string]any)["parent"] = nested
+
+	for name, src := range map[string]map[string]any{"self": self, "nested": nested} {
+		src := src
+		t.Run(name, func(t *testing.T) {
+			testMergeFuncs(t, func(t *testing.T, merge mergeFunc) {
+				for _, opts := range []Options{nil, {WithOverwrite()}} {
+					dst := map[string]any{"b": 2}
+					if err := merge(dst, src, opts...); err != nil {
+						t.Fatal(err)
+					}
+					if dst["a"] != 1 || dst["b"] != 2 {
+						t.Errorf("got %v", dst)
+					}
+				}
+			})
+		})
+	}
+}
+
+func TestMergeWithFloatRound(t *testing.T) {
+	t.Parallel()
+
+	type T struct {
+		F64 float64
+		F32 float32
+		I   int
+	}
+
+	tests := []test{
+		{
+			dst:       &T{},
+			src:       T{1.23456, 2.5, 3},
+			mergeOpts: Options{WithFloatRound(2)},
+			want:      &T{1.23, 2.5, 3},
+		},
+		{
+			dst:       &T{F64: 1.001},
+			src:       T{F64: 0.0049},
+			mergeOpts: Options{WithFloatRound(2), WithNumericAdd()},
+			want:      &T{F64: 1.01},
+		},
+		{
+			dst:       &T{F64: 9.9},
+			src:       T{F64: 12.345},
+			mergeOpts: Options{WithFloatRound(0), WithOverwrite()},
+			want:      &T{F64: 12},
+		},
+	}
+
+	t.Run("Merge", func(t *testing.T) { testDeepMerge(t, tests...) })
+
+	t.Run("Map", func(t *testing.T) {
+		tests := append(tests[:1:1], tests[2], test{
+			dst:       &T{},
+			src:       map[string]any{"f64": float32(0.125), "f32": 1.98765},
+			mergeOpts: Options{WithFloatRound(1)},
+			want:      &T{F64: 0.1, F32: 2},
+		})
+		testDeepMap(t, tests...)
+	})
+}
+
+func TestMergeWithFloatTolerance(t *testing.T) {
+	t.Parallel()
+
+	type T struct {
+		F64 float64
+		F32 float32
+	}
+
+	opts := Options{WithFloatTolerance(0.01), WithOverwrite()}
+	tests := []test{
+		{
+			name:      "inside",
+			dst:       &T{1.0, 2.0},
+			src:       T{1.005, 1.995},
+			mergeOpts: opts,
+			want:      &T{1.0, 2.0},
+		},
+		{
+			name:      "outside",
+			dst:       &T{1.0, 2.0},
+			src:       T{1.02, 1.5},
+			mergeOpts: opts,
+			want:      &T{1.02, 1.5},
+		},
+		{
+			name:      "zero dst",
+			dst:       &T{},
+			src:       T{0.005, 3},
+			mergeOpts: opts,
+			want:      &T{0.005, 3},
+		},
+		{
+			name:      "no overwrite",
+			dst:       &T{1.0, 2.0},
+			src:       T{1.005, 5},
+			mergeOpts: Options{WithFloatTolerance(0.01)},
+			want:      &T{1.0, 2.0},
+		},
+		{
+			name:      "add",
+			dst:       &T{F64: 1.0},
+			src:       T{F64: 0.005},
+			mergeOpts: Options{WithFloatTolerance(0.01), WithNumericAdd()},
+			want:      &T{F64: 1.005},
+		},
+		{
+			name:      "negative",
+			dst:       &T{},
+			src:       T{},
+			mergeOpts: Options{WithFloatTolerance(-1)},
+			wantErr:   true,
+		},
+	}
+
+	t.Run("Merge", func(t *testing.T) { testDeepMerge(t, tests...) })
+
+	t.Run("Map", func(t *testing.T) {
+		tests := append(tests[:3:3], test{
+			name:      "from map",
+			dst:       &T{1.0, 2.0},
+			src:       map[string]any{"f64": 1.005, "f32": 3},
+			mergeOpts: opts,
+			want:      &T{1.0, 3},
+		})
+		testDeepMap(t, tests...)
+	})
+}
+
+func TestChannelPolicy(t *testing.T) {
+	t.Parallel()
+
+	type T struct{ C chan int }
+	ch1, ch2 := make(chan int), make(chan int)
+
+	policy := WithChannelPolicy(ChannelOverwriteNonNil)
+	tests := []test{
+		{
+			dst:       &T{},
+			src:       T{ch1},
+			mergeOpts: Options{policy},
+			want:      &T{ch1},
+		},
+		{
+			dst:       &T{ch1},
+			src:       T{ch2},
+			mergeOpts: Options{policy, WithOverwrite()},
+			want:      &T{ch1},
+		},
+		{
+			dst:       &T{ch1},
+			src:       T{},
+			mergeOpts: Options{policy, WithOverwriteWithEmptyValue()},
+			want:      &T{ch1},
+		},
+		{
+			dst:       &T{ch1},
+			src:       T{ch2},
+			mergeOpts: Options{WithChannelPolicy(ChannelKeep), WithOverwrite()},
+			want:      &T{ch2},
+		},
+	}
+
+	t.Run("Merge", func(t *testing.T) { testDeepMerge(t, tests...) })
+
+	t.Run("Map", func(t *testing.T) { testDeepMap(t, tests...) })
+}
+
+func TestMergeWithOverwriteIf(t *testing.T) {
+	t.Parallel()
+
+	type T struct {
+		Max     int
+		Name    string
+		Updated time.Time
+	}
+
+	now := time.Now()
+	earlier := now.Add(-time.Hour)
+
+	greater := WithOverwriteIf(func(dst, src reflect.Value) bool {
+		if dst.CanInt() {
+			return src.Int() > dst.Int()
+		}
+		if d, ok := dst.Interface().(time.Time); ok {
+			return src.Interface().(time.Time).After(d)
+		}
+		return false
+	})
+
+	tests := []test{
+		{
+			dst:       &T{Max: 5, Name: "foo", Updated: earlier},
+			src:       T{Max: 7, Name: "bar", Updated: now},
+			mergeOpts: Options{greater},
+			want:      &T{Max: 7, Name: "foo", Updated: now},
+		},
+		{
+			dst:       &T{Max: 5, Name: "foo", Updated: now},
+			src:       T{Max: 3, Name: "bar", Updated: earlier},
+			mergeOpts: Options{greater},
+			want:      &T{Max: 5, Name: "foo", Updated: now},
+		},
+		{
+			dst:       &T{},
+			src:       T{Max: -1, Name: "bar"},
+			mergeOpts: Options{greater},
+			want:      &T{Max: -1, Name: "bar"},
+		},
+	}
+
+	testDeepMerge(t, tests...)
+}
+
+func TestMergeWithDryRun(t *testing.T) {
+	t.Parallel()
+
+	type Inner struct{ N int }
+	type T struct {
+		A     string
+		B     int
+		Inner *Inner
+		M     map[string]int
+	}
+
+	newDst := func() *T { return &T{A: "foo", M: map[string]int{"a": 1, "b": 2}} }
+	src := T{A: "bar", B: 1, Inner: &Inner{2}, M: map[string]int{"a": 3}}
+
+	var changes []Change
+	dst := newDst()
+	if err := DeepMerge(dst, src, WithOverwriteWithEmptyValue(), WithPruneMissingKeys(), WithDryRun(&changes)); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := newDst(); !cmp.Equal(want, dst) {
+		t.Errorf("dst was modified: %s", cmp.Diff(want, dst))
+	}
+
+	want := []Change{
+		{".A", "foo", "bar"},
+		{".B", 0, 1},
+		{"(*.Inner).N", 0, 2},
+		{".M[a]", 1, 3},
+		{".M[b]", 2, nil},
+	}
+	if !cmp.Equal(want, changes) {
+		t.Error(cmp.Diff(want, changes))
+	}
+
+	var pp *T
+	changes = nil
+	if err := DeepMerge(&pp, src, WithDryRun(&changes)); err != nil {
+		t.Fatal(err)
+	}
+	if pp != nil {
+		t.Errorf("nil dst was allocated: %#v", pp)
+	}
+	if len(changes) == 0 {
+		t.Error("no changes recorded")
+	}
+}
+
+func TestMergeWithNumericReduce(t *testing.T) {
+	t.Parallel()
+
+	type Latency float64
+	type Stats struct {
+		Max, Min, Total int
+		Count           uint
+		P99             Latency
+	}
+
+	partials := []Stats{
+		{Max: 3, Min: 3, Total: 3, Count: 1, P99: 1.5},
+		{Max: 7, Min: 7, Total: 7, Count: 1, P99: 0.5},
+		{Max: 5, Min: 2, Total: 2, Count: 1, P99: 2.5},
+	}
+	opts := Options{
+		WithNumericReduce(".Max", ReduceMax),
+		WithNumericReduce(".Min", ReduceMin),
+		WithNumericReduce(reflect.TypeOf(0), ReduceSum),
+		WithNumericReduce(reflect.TypeOf(uint(0)), ReduceSum),
+		WithNumericReduce(reflect.TypeOf(Latency(0)), ReduceMax),
+	}
+
+	var got Stats
+	for _, p := range partials {
+		if err := DeepMerge(&got, p, opts...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := Stats{Max: 7, Min: 2, Total: 12, Count: 3, P99: 2.5}
+	if !cmp.Equal(want, got) {
+		t.Error(cmp.Diff(want, got))
+	}
+
+	testDeepMerge(t, test{
+		name:      "complex max",
+		dst:       New(1 + 1i),
+		src:       2 + 2i,
+		mergeOpts: Options{WithNumericReduce(reflect.TypeOf(0i), ReduceMax)},
+		wantErr:   true,
+	})
+}
+
+func TestMergeEmbeddedPointer(t *testing.T) {
+	t.Parallel()
+
+	type Inner struct{ A, B int }
+	type Outer struct {
+		*Inner
+		C int
+	}
+
+	tests := []test{
+		{
+			name: "nil dst",
+			dst:  &Outer{C: 1},
+			src:  Outer{&Inner{A: 2, B: 3}, 4},
+			want: &Outer{&Inner{A: 2, B: 3}, 1},
+		},
+		{
+			name: "nil src",
+			dst:  &Outer{&Inner{A: 1}, 2},
+			src:  Outer{nil, 3},
+			want: &Outer{&Inner{A: 1}, 2},
+		},
+		{
+			name:      "nil src with overwrite",
+			dst:       &Outer{&Inner{A: 1}, 2},
+			src:       Outer{nil, 3},
+			mergeOpts: Options{WithOverwrite()},
+			want:      &Outer{&Inner{A: 1}, 3},
+		},
+		{
+			name:      "promoted fields",
+			dst:       &Outer{&Inner{A: 1}, 2},
+			src:       Outer{&Inner{A: 3, B: 4}, 0},
+			mergeOpts: Options{WithOverwrite()},
+			want:      &Outer{&Inner{A: 3, B: 4}, 2},
+		},
+	}
+
+	testDeepMerge(t, tests...)
+
+	type inner struct{ A int }
+	type outer struct {
+		*inner
+		B int
+	}
+
+	testDeepMerge(t, []test{
+		{
+			name:    "unexported nil dst",
+			dst:     &outer{B: 1},
+			src:     outer{&inner{2}, 3},
+			want:    &outer{nil, 1},
+			cmpOpts: cmp.Options{cmp.AllowUnexported(outer{})},
+		},
+		{
+			name:      "unexported promoted fields",
+			dst:       &outer{&inner{1}, 1},
+			src:       outer{&inner{2}, 3},
+			mergeOpts: Options{WithOverwrite()},
+			want:      &outer{&inner{2}, 3},
+			cmpOpts:   cmp.Options{cmp.AllowUnexported(outer{})},
+		},
+	}...)
+}
+
+func TestMergeInterfaceDynamicType(t *testing.T) {
+	t.Parallel()
+
+	type T struct{ V any }
+
+	tests := []test{
+		{
+			name:      "adopt src type",
+			dst:       &T{1},
+			src:       T{"foo"},
+			mergeOpts: Options{WithOverwrite()},
+			want:      &T{"foo"},
+		},
+		{
+			name:      "adopt src type in map",
+			dst:       map[string]any{"v": 1},
+			src:       map[string]any{"v": 2.5},
+			mergeOpts: Options{WithOverwrite()},
+			want:      map[string]any{"v": 2.5},
+		},
+		{
+			name:      "preserve dst type",
+			dst:       &T{int64(1)},
+			src:       T{2.5},
+			mergeOpts: Options{WithOverwrite(), WithPreserveDstDynamicType()},
+			want:      &T{int64(2)},
+		},
+		{
+			name:      "preserve dst type not convertible",
+			dst:       &T{1},
+			src:       T{"foo"},
+			mergeOpts: Options{WithOverwrite(), WithPreserveDstDynamicType()},
+			wantErr:   true,
+		},
+		{
+			name:      "preserve dst string type from int",
+			dst:       &T{"foo"},
+			src:       T{65},
+			mergeOpts: Options{WithOverwrite(), WithPreserveDstDynamicType()},
+			wantErr:   true,
+		},
+	}
+
+	testDeepMerge(t, tests...)
+
+	t.Run("dynamic type", func(t *testing.T) {
+		dst := T{1}
+		if err := DeepMerge(&dst, T{"foo"}, WithOverwrite()); err != nil {
+			t.Fatal(err)
+		}
+		if got := reflect.TypeOf(dst.V); got != reflect.TypeOf("") {
+			t.Errorf("dynamic type = %v, want string", got)
+		}
+	})
+}
+
+type wideStruct struct {
+	A0, A1, A2, A3, A4, A5, A6, A7, A8, A9 int
+	B0, B1, B2, B3, B4, B5, B6, B7, B8, B9 string
+	C0, C1, C2, C3, C4, C5, C6, C7, C8, C9 float64
+	D0, D1, D2, D3, D4, D5, D6, D7, D8, D9 bool
+}
+
+func BenchmarkDeepMergeWideStruct(b *testing.B) {
+	src := wideStruct{A0: 1, A9: 9, B0: "foo", B9: "bar", C0: 1.5, D9: true}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var dst wideStruct
+		if err := DeepMerge(&dst, src, WithOverwrite()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDeepMapWideStruct(b *testing.B) {
+	src := wideStruct{A0: 1, A9: 9, B0: "foo", B9: "bar", C0: 1.5, D9: true}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		dst := make(map[string]any)
+		if err := DeepMap(dst, src); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestMergeSliceOfPointersWithNilElements(t *testing.T) {
+	t.Parallel()
+
+	newDst := func() []*int { return []*int{nil, New(1), nil} }
+	src := []*int{New(2), nil, New(3)}
+
+	tests := []test{
+		{
+			name: "default",
+			dst:  newDst(),
+			src:  src,
+			want: []*int{New(2), New(1), New(3)},
+		},
+		{
+			name:      "overwrite",
+			dst:       newDst(),
+			src:       src,
+			mergeOpts: Options{WithOverwrite()},
+			want:      []*int{New(2), New(1), New(3)},
+		},
+		{
+			name:      "overwrite with empty value",
+			dst:       newDst(),
+			src:       src,
+			mergeOpts: Options{WithOverwriteWithEmptyValue()},
+			want:      []*int{New(2), New(0), New(3)},
+		},
+		{
+			name: "both nil",
+			dst:  []*int{nil, New(1)},
+			src:  []*int{nil, nil},
+			want: []*int{nil, New(1)},
+		},
+	}
+
+	testDeepMerge(t, tests...)
+
+	t.Run("no aliasing", func(t *testing.T) {
+		dst := newDst()
+		if err := DeepMerge(dst, src); err != nil {
+			t.Fatal(err)
+		}
+		if dst[0] == src[0] || dst[2] == src[2] {
+			t.Error("allocated dst elements alias src elements")
+		}
+	})
+}
+
+func TestMergeSliceOfStructPointersNoAliasing(t *testing.T) {
+	t.Parallel()
+
+	type T struct{ A int }
+	testMergeFuncs(t, func(t *testing.T, merge mergeFunc) {
+		for _, opts := range []Options{nil, {WithOverwrite()}, {WithoutDereference()}} {
+			src := []*T{{1}, {2}}
+			dst := []*T{nil}
+			if err := merge(&dst, src, opts...); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff([]*T{{1}, {2}}, dst); diff != "" {
+				t.Error(diff)
+			}
+
+			dst[0].A, dst[1].A = 3, 4
+			if src[0].A != 1 || src[1].A != 2 {
+				t.Errorf("mutating dst changed src to %+v %+v", src[0], src[1])
+			}
+		}
+	})
+}
+
+func TestMergeConvertibleTypes(t *testing.T) {
+	t.Parallel()
+
+	type Celsius float64
+	type Reading struct {
+		Temp any
+	}
+	type Names []string
+
+	tests := []test{
+		{
+			name: "named float from float64",
+			dst:  New(Celsius(0)),
+			src:  21.5,
+			want: New(Celsius(21.5)),
+		},
+		{
+			name:      "named float overwrite",
+			dst:       New(Celsius(10)),
+			src:       21.5,
+			mergeOpts: Options{WithOverwrite()},
+			want:      New(Celsius(21.5)),
+		},
+		{
+			name: "named slice",
+			dst:  New(Names{"", "b"}),
+			src:  []string{"a", "c"},
+			want: New(Names{"a", "b"}),
+		},
+		{
+			name: "interface holding a named type",
+			dst:  &Reading{Celsius(0)},
+			src:  Reading{21.5},
+			want: &Reading{Celsius(21.5)},
+		},
+		{
+			name:      "type check",
+			dst:       New(Celsius(10)),
+			src:       21.5,
+			mergeOpts: Options{WithOverwrite(), WithTypeCheck()},
+			want:      New(Celsius(21.5)),
+		},
+		{
+			name:      "type check named slice",
+			dst:       New(Names{"a"}),
+			src:       []string{"b"},
+			mergeOpts: Options{WithOverwrite(), WithTypeCheck()},
+			want:      New(Names{"b"}),
+		},
+		{
+			name:    "different kinds",
+			dst:     New(Celsius(0)),
+			src:     21,
+			wantErr: true,
+		},
+	}
+
+	t.Run("Merge", func(t *testing.T) { testDeepMerge(t, tests...) })
+}
+
+func TestMergeSliceWithPreferLongerSlice(t *testing.T) {
+	t.Parallel()
+
+	opts := Options{WithPreferLongerSlice(), WithOverwriteWithEmptyValue()}
+	tests := []test{
+		{
+			name:      "longer src",
+			dst:       New([]int{1, 2}),
+			src:       []int{3, 4, 5, 6},
+			mergeOpts: opts,
+			want:      New([]int{3, 4, 5, 6}),
+		},
+		{
+			name:      "longer dst",
+			dst:       New([]int{1, 2, 3, 4}),
+			src:       []int{5, 6},
+			mergeOpts: opts,
+			want:      New([]int{5, 6, 3, 4}),
+		},
+		{
+			name:      "longer dst without option",
+			dst:       New([]int{1, 2, 3, 4}),
+			src:       []int{5, 6},
+			mergeOpts: Options{WithOverwriteWithEmptyValue()},
+			want:      New([]int{5, 6, 0, 0}),
+		},
+		{
+			name:      "with append",
+			dst:       New([]int{1}),
+			src:       []int{2},
+			mergeOpts: Options{WithPreferLongerSlice(), WithAppendSlice()},
+			wantErr:   true,
+		},
+	}
+
+	t.Run("Merge", func(t *testing.T) { testDeepMerge(t, tests...) })
+
+	t.Run("Map", func(t *testing.T) { testDeepMap(t, tests...) })
+}
+
+func TestMergeSliceWithSliceTruncate(t *testing.T) {
+	t.Parallel()
+
+	type T struct{ S []int }
+
+	opts := Options{WithSliceTruncate(), WithOverwrite()}
+	tests := []test{
+		{
+			name:      "longer dst",
+			dst:       New([]int{1, 2, 3, 4}),
+			src:       []int{5, 6},
+			mergeOpts: opts,
+			want:      New([]int{5, 6}),
+		},
+		{
+			name:      "equal",
+			dst:       New([]int{1, 2}),
+			src:       []int{3, 0},
+			mergeOpts: opts,
+			want:      New([]int{3, 2}),
+		},
+		{
+			name:      "shorter dst",
+			dst:       New([]int{1}),
+			src:       []int{2, 3, 4},
+			mergeOpts: opts,
+			want:      New([]int{2, 3, 4}),
+		},
+		{
+			name:      "field",
+			dst:       &T{S: []int{1, 2, 3}},
+			src:       T{S: []int{0, 4}},
+			mergeOpts: Options{WithSliceTruncate()},
+			want:      &T{S: []int{1, 2}},
+		},
+		{
+			name:      "with prefer longer slice",
+			dst:       New([]int{1}),
+			src:       []int{2},
+			mergeOpts: Options{WithSliceTruncate(), WithPreferLongerSlice()},
+			wantErr:   true,
+		},
+	}
+
+	t.Run("Merge", func(t *testing.T) { testDeepMerge(t, tests...) })
+
+	t.Run("Map", func(t *testing.T) { testDeepMap(t, tests...) })
+}
+
+func TestMergeWithSliceStrategy(t *testing.T) {
+	t.Parallel()
+
+	type T struct{ S []*int }
+	two := New(2)
+
+	tests := []test{
+		{
+			name:      "merge by index",
+			dst:       New([]int{1, 0, 3}),
+			src:       []int{4, 5},
+			mergeOpts: Options{WithSliceStrategy(SliceMergeByIndex)},
+			want:      New([]int{1, 5, 3}),
+		},
+		{
+			name:      "replace",
+			dst:       New([]int{1, 0, 3}),
+			src:       []int{4, 0},
+			mergeOpts: Options{WithSliceStrategy(SliceReplace)},
+			want:      New([]int{4, 0}),
+		},
+		{
+			name:      "replace with empty src",
+			dst:       New([]int{1}),
+			src:       []int{},
+			mergeOpts: Options{WithSliceStrategy(SliceReplace)},
+			want:      New([]int{1}),
+		},
+		{
+			name:      "replace with empty src and overwrite with empty value",
+			dst:       New([]int{1}),
+			src:       []int(nil),
+			mergeOpts: Options{WithSliceStrategy(SliceReplace), WithOverwriteWithEmptyValue()},
+			want:      New([]int(nil)),
+		},
+		{
+			name:      "replace copies",
+			dst:       &T{S: []*int{New(1)}},
+			src:       T{S: []*int{two}},
+			mergeOpts: Options{WithSliceStrategy(SliceReplace)},
+			want:      &T{S: []*int{New(2)}},
+			check: func(t testing.TB, dst any) {
+				if dst.(*T).S[0] == two {
+					t.Error("dst element aliases src element")
+				}
+			},
+		},
+		{
+			name:      "append",
+			dst:       New([]int{1, 2}),
+			src:       []int{2, 3},
+			mergeOpts: Options{WithSliceStrategy(SliceAppend)},
+			want:      New([]int{1, 2, 2, 3}),
+		},
+		{
+			name:      "append unique",
+			dst:       New([]string{"a", "b"}),
+			src:       []string{"b", "c", "c", "d"},
+			mergeOpts: Options{WithSliceStrategy(SliceAppendUnique)},
+			want:      New([]string{"a", "b", "c", "d"}),
+		},
+		{
+			name:      "truncate",
+			dst:       New([]int{1, 2, 3}),
+			src:       []int{4, 5},
+			mergeOpts: Options{WithSliceStrategy(SliceTruncate), WithOverwrite()},
+			want:      New([]int{4, 5}),
+		},
+		{
+			name:      "last strategy wins",
+			dst:       New([]int{1}),
+			src:       []int{2},
+			mergeOpts: Options{WithAppendSlice(), WithSliceStrategy(SliceReplace)},
+			want:      New([]int{2}),
+		},
+		{
+			name:      "with prepend slice",
+			dst:       New([]int{1}),
+			src:       []int{2},
+			mergeOpts: Options{WithSliceStrategy(SliceAppendUnique), WithPrependSlice()},
+			wantErr:   true,
+		},
+		{
+			name:      "with prefer longer slice",
+			dst:       New([]int{1}),
+			src:       []int{2},
+			mergeOpts: Options{WithSliceStrategy(SliceReplace), WithPreferLongerSlice()},
+			wantErr:   true,
+		},
+	}
+
+	t.Run("Merge", func(t *testing.T) { testDeepMerge(t, tests...) })
+
+	t.Run("Map", func(t *testing.T) { testDeepMap(t, tests...) })
+}
+
+func TestMergeNamedIntegerTypes(t *testing.T) {
+	t.Parallel()
+
+	type Level int
+	type T struct {
+		Timeout time.Duration
+		Level   Level
+	}
+
+	tests := []test{
+		{
+			name: "duration from int64",
+			dst:  New(time.Duration(0)),
+			src:  int64(time.Second),
+			want: New(time.Second),
+		},
+		{
+			name:      "int64 from duration",
+			dst:       New(int64(1)),
+			src:       time.Second,
+			mergeOpts: Options{WithOverwrite()},
+			want:      New(int64(time.Second)),
+		},
+		{
+			name:      "level from int with type check",
+			dst:       New(Level(1)),
+			src:       2,
+			mergeOpts: Options{WithOverwrite(), WithTypeCheck()},
+			want:      New(Level(2)),
+		},
+		{
+			name:      "int from level with type check",
+			dst:       New(1),
+			src:       Level(2),
+			mergeOpts: Options{WithOverwrite(), WithTypeCheck()},
+			want:      New(2),
+		},
+		{
+			name:      "duration from int64 with type check",
+			dst:       New(time.Duration(1)),
+			src:       int64(time.Minute),
+			mergeOpts: Options{WithOverwrite(), WithTypeCheck()},
+			want:      New(time.Minute),
+		},
+		{
+			name:      "different kinds with type check",
+			dst:       New(time.Duration(1)),
+			src:       2,
+			mergeOpts: Options{WithOverwrite(), WithTypeCheck()},
+			wantErr:   true,
+		},
+	}
+
+	t.Run("Merge", func(t *testing.T) { testDeepMerge(t, tests...) })
+
+	tests = append(tests, test{
+		name:      "struct fields from map with type check",
+		dst:       &T{Timeout: 1, Level: 1},
+		src:       map[string]any{"timeout": int64(time.Second), "level": 3},
+		mergeOpts: Options{WithOverwrite(), WithTypeCheck()},
+		want:      &T{Timeout: time.Second, Level: 3},
+	})
+	t.Run("Map", func(t *testing.T) { testDeepMap(t, tests...) })
+}
+
+func TestMergeSliceWithPreserveCap(t *testing.T) {
+	t.Parallel()
+
+	tests := []test{
+		{
+			name:      "grow",
+			dst:       &[]int{1, 2},
+			src:       []int{3, 4, 5},
+			mergeOpts: Options{WithOverwrite(), WithPreserveCap()},
+			want:      &[]int{3, 4, 5},
+			check: func(t testing.TB, dst any) {
+				if s := *dst.(*[]int); cap(s) <= len(s) {
+					t.Errorf("cap = %d, want > %d", cap(s), len(s))
+				}
+			},
+		},
+		{
+			name:      "within cap",
+			dst:       New(append(make([]int, 0, 8), 1)),
+			src:       []int{2, 3},
+			mergeOpts: Options{WithPreserveCap()},
+			want:      &[]int{1, 3},
+			check: func(t testing.TB, dst any) {
+				if s := *dst.(*[]int); cap(s) != 8 {
+					t.Errorf("cap = %d, want 8", cap(s))
+				}
+			},
+		},
+	}
+
+	t.Run("Merge", func(t *testing.T) { testDeepMerge(t, tests...) })
+
+	t.Run("Map", func(t *testing.T) { testDeepMap(t, tests...) })
+}
+
+func TestMergeSliceWithCopyOnGrow(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		name      string
+		mergeOpts Options
+		aliased   bool
+	}{
+		{"default", nil, true},
+		{"copy on grow", Options{WithCopyOnGrow()}, false},
+		{"copy on grow with preserve cap", Options{WithCopyOnGrow(), WithPreserveCap()}, false},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			testMergeFuncs(t, func(t *testing.T, merge mergeFunc) {
+				// tail shares the backing array of dst beyond its length.
+				backing := []int{1, 0, 9}
+				dst, tail := backing[:1], backing[1:]
+				if err := merge(&dst, []int{0, 2, 3}, tt.mergeOpts...); err != nil {
+					t.Fatal(err)
+				}
+
+				// Reusing the capacity exposes the hidden 9, which is then kept,
+				// and writes through to tail.
+				want, wantTail := []int{1, 2, 3}, []int{0, 9}
+				if tt.aliased {
+					want, wantTail = []int{1, 2, 9}, []int{2, 9}
+				}
+				if !cmp.Equal(want, dst) {
+					t.Error(cmp.Diff(want, dst))
+				}
+				if !cmp.Equal(wantTail, tail) {
+					t.Errorf("tail %v, want %v", tail, wantTail)
+				}
+				if cp := cap(dst); tt.mergeOpts != nil && cp != 3 {
+					t.Errorf("cap = %d, want 3", cp)
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkDeepMergeGrowingSlice(b *testing.B) {
+	src := make([]int, 64)
+	for i := range src {
+		src[i] = i + 1
+	}
+
+	for _, bb := range []struct {
+		name string
+		opts Options
+	}{
+		{"Exact", nil},
+		{"PreserveCap", Options{WithPreserveCap()}},
+	} {
+		b.Run(bb.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				var dst []int
+				for n := 1; n <= len(src); n++ {
+					if err := DeepMerge(&dst, src[:n], bb.opts...); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkDeepMergeParallelMap(b *testing.B) {
+	type Item struct {
+		N    int
+		Name string
+		Tags []string
+	}
+
+	const n = 100_000
+	src := make(map[string]Item, n)
+	for i := 0; i < n; i++ {
+		src[strconv.Itoa(i)] = Item{N: i, Name: "item", Tags: []string{"a", "b"}}
+	}
+
+	for _, bb := range []struct {
+		name string
+		opts Options
+	}{
+		{"Sequential", nil},
+		{"Parallel", Options{WithParallelism(runtime.GOMAXPROCS(0))}},
+	} {
+		b.Run(bb.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				dst := make(map[string]Item, n)
+				for k := range src {
+					dst[k] = Item{Tags: []string{"c"}}
+				}
+				b.StartTimer()
+
+				if err := DeepMerge(&dst, src, bb.opts...); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestMergeIntoInterfaceHoldingPointer(t *testing.T) {
+	t.Parallel()
+
+	type T struct{ A, B int }
+
+	tests := []test{
+		{
+			name: "named struct",
+			dst:  New[any](&T{A: 1}),
+			src:  T{A: 2, B: 3},
+			want: New[any](&T{A: 1, B: 3}),
+		},
+		{
+			name: "anonymous struct",
+			dst:  New[any](&struct{ A, B int }{A: 1}),
+			src:  struct{ A, B int }{A: 2, B: 3},
+			want: New[any](&struct{ A, B int }{A: 1, B: 3}),
+		},
+		{
+			name:      "convertible struct",
+			dst:       New[any](&struct{ A, B int }{A: 1}),
+			src:       T{A: 2, B: 3},
+			mergeOpts: Options{WithOverwrite()},
+			want:      New[any](&struct{ A, B int }{A: 2, B: 3}),
+		},
+		{
+			name:      "src pointer in interface",
+			dst:       New[any](&T{A: 1}),
+			src:       New[any](&T{A: 2, B: 3}),
+			mergeOpts: Options{WithOverwrite()},
+			want:      New[any](&T{A: 2, B: 3}),
+		},
+		{
+			name: "struct value in interface",
+			dst:  New[any](T{A: 1}),
+			src:  T{A: 2, B: 3},
+			want: New[any](T{A: 1, B: 3}),
+		},
+	}
+
+	testDeepMerge(t, tests...)
+}
+
+func TestMergeArraysOfDifferentLengths(t *testing.T) {
+	t.Parallel()
+
+	tests := []test{
+		{
+			name: "shorter src",
+			dst:  &[3]int{1, 0, 0},
+			src:  [2]int{2, 3},
+			want: &[3]int{1, 3, 0},
+		},
+		{
+			name: "longer src",
+			dst:  &[2]int{1, 0},
+			src:  [3]int{2, 3, 4},
+			want: &[2]int{1, 3},
+		},
+		{
+			name: "empty src",
+			dst:  &[2]int{1, 2},
+			src:  [0]int{},
+			want: &[2]int{1, 2},
+		},
+	}
+
+	t.Run("Map", func(t *testing.T) { testDeepMap(t, tests...) })
+
+	// Merge also zeroes the dst elements beyond the length of src.
+	tests = append(tests, test{
+		name:      "shorter src with empty value",
+		dst:       &[3]int{1, 2, 3},
+		src:       [2]int{4, 0},
+		mergeOpts: Options{WithOverwriteWithEmptyValue()},
+		want:      &[3]int{4, 0, 0},
+	})
+	t.Run("Merge", func(t *testing.T) { testDeepMerge(t, tests...) })
+}
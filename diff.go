@@ -0,0 +1,244 @@
+package merge
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// differ records the changes found by deepValueDiff.
+type differ struct {
+	changes []Change
+	first   bool // stop at the first change, for Equal
+}
+
+func (d *differ) add(path string, a, b any) {
+	d.changes = append(d.changes, Change{path, a, b})
+}
+
+// done reports whether deepValueDiff can stop looking for changes.
+func (d *differ) done() bool {
+	return d.first && len(d.changes) > 0
+}
+
+// diffVisit is a pair of references compared by deepValueDiff.
+type diffVisit struct {
+	a, b pointer
+	typ  reflect.Type
+}
+
+// Diffs for deep diff using reflected types. The map argument tracks
+// comparisons that have already been seen, which allows short circuiting on
+// recursive types. Paths are built like those of deepValueMerge.
+// Struct fields filtered out by c are skipped, values of types with
+// a transformer in c are compared as a whole, and values that c reports
+// as empty are equal.
+func deepValueDiff(path string, a, b reflect.Value, visited map[diffVisit]bool, d *differ, c *Config) {
+	if d.done() {
+		return
+	}
+	if !a.IsValid() || !b.IsValid() {
+		if a.IsValid() != b.IsValid() {
+			d.add(path, valueInterface(a), valueInterface(b))
+		}
+		return
+	}
+	if a.Type() != b.Type() {
+		d.add(path, valueInterface(a), valueInterface(b))
+		return
+	}
+
+	if c.emptyFunc != nil && c.isZero(a) && c.isZero(b) {
+		return
+	}
+	if c.transformers[a.Type()].IsValid() {
+		if a.CanInterface() && !reflect.DeepEqual(a.Interface(), b.Interface()) {
+			d.add(path, a.Interface(), b.Interface())
+		}
+		return
+	}
+
+	switch a.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice:
+		if a.IsNil() || b.IsNil() {
+			if a.IsNil() != b.IsNil() {
+				d.add(path, valueInterface(a), valueInterface(b))
+			}
+			return
+		}
+		if valuePointer(a) == valuePointer(b) && (reflect.Slice != a.Kind() || a.Len() == b.Len()) {
+			return
+		}
+
+		// Short circuit if this pair of references is already seen.
+		v := diffVisit{valuePointer(a), valuePointer(b), a.Type()}
+		if visited[v] {
+			return
+		}
+		visited[v] = true
+	}
+
+	switch a.Kind() {
+	case reflect.Array, reflect.Slice:
+		if a.Len() != b.Len() {
+			d.add(path, valueInterface(a), valueInterface(b))
+			return
+		}
+		for i := 0; i < a.Len() && !d.done(); i++ {
+			deepValueDiff(fmt.Sprintf("%s[%d]", path, i), a.Index(i), b.Index(i), visited, d, c)
+		}
+	case reflect.Interface:
+		if a.IsNil() || b.IsNil() || a.Elem().Type() != b.Elem().Type() {
+			if a.IsNil() != b.IsNil() || !a.IsNil() {
+				d.add(path, valueInterface(a), valueInterface(b))
+			}
+			return
+		}
+		deepValueDiff(fmt.Sprintf("%s(%s)", path, a.Type()), a.Elem(), b.Elem(), visited, d, c)
+	case reflect.Pointer:
+		deepValueDiff(fmt.Sprintf("(*%s)", path), a.Elem(), b.Elem(), visited, d, c)
+	case reflect.Struct:
+		expose := c.unexportedFields && canExposeUnexported && cachedFields(a.Type()).mergeable
+		if expose {
+			a, b = addressable(a), addressable(b)
+		}
+
+		var hasExportedField bool
+		for i, n := 0, a.NumField(); i < n && !d.done(); i++ {
+			af, bf := a.Field(i), b.Field(i)
+			sf := a.Type().Field(i)
+			if !sf.IsExported() {
+				if !expose {
+					continue
+				}
+				af, bf = exposeUnexported(af), exposeUnexported(bf)
+			}
+
+			hasExportedField = true
+			fieldPath := path + "." + sf.Name
+			if !c.filterField(fieldPath, sf) {
+				continue
+			}
+			deepValueDiff(fieldPath, af, bf, visited, d, c)
+		}
+
+		// Structs without exported fields, such as time.Time, are compared as a whole.
+		if !hasExportedField && a.CanInterface() && !reflect.DeepEqual(a.Interface(), b.Interface()) {
+			d.add(path, a.Interface(), b.Interface())
+		}
+	case reflect.Map:
+		for it := c.mapRange(a); it.Next() && !d.done(); {
+			k := it.Key()
+			deepValueDiff(fmt.Sprintf("%s[%v]", path, k), it.Value(), b.MapIndex(k), visited, d, c)
+		}
+		for it := c.mapRange(b); it.Next() && !d.done(); {
+			k := it.Key()
+			if !a.MapIndex(k).IsValid() {
+				d.add(fmt.Sprintf("%s[%v]", path, k), nil, valueInterface(it.Value()))
+			}
+		}
+	case reflect.Func:
+		if a.Pointer() != b.Pointer() {
+			d.add(path, valueInterface(a), valueInterface(b))
+		}
+	default:
+		if a.CanInterface() && !reflect.DeepEqual(a.Interface(), b.Interface()) {
+			d.add(path, a.Interface(), b.Interface())
+		}
+	}
+}
+
+// addressable returns v, or an addressable copy of it.
+func addressable(v reflect.Value) reflect.Value {
+	if v.CanAddr() {
+		return v
+	}
+	cp := reflect.New(v.Type()).Elem()
+	cp.Set(v)
+	return cp
+}
+
+// ApplyAndDiff deeply merges src into dst, like DeepMerge, and returns the changes
+// it made to dst: for every value that differs between dst before and after the merge,
+// its path and its old and new values. A map key added or deleted by the merge
+// has a nil Old or New value respectively.
+func ApplyAndDiff(dst, src any, opts ...Option) ([]Change, error) {
+	if dst == nil || src == nil {
+		return nil, errors.New("dst or src is nil")
+	}
+
+	var c Config
+	Options(opts).apply(&c)
+
+	vdst := reflect.ValueOf(dst)
+	before := c.copyOf(vdst)
+	if err := DeepMerge(dst, src, opts...); err != nil {
+		return nil, err
+	}
+
+	// Like DeepMerge, compare what dst points to.
+	after := vdst
+	for reflect.Pointer == after.Kind() && !before.IsNil() && !after.IsNil() {
+		before, after = before.Elem(), after.Elem()
+	}
+
+	// Compare the unexported fields that WithUnexportedFields merges too.
+	var d differ
+	deepValueDiff("", before, after, make(map[diffVisit]bool), &d, &Config{unexportedFields: c.unexportedFields, sortedMapKeys: c.sortedMapKeys})
+	return d.changes, nil
+}
+
+// Diff reports the values that differ between a and b, which must have the same type:
+// for every differing value, its path and its values in a and b as Old and New.
+// Leaves are compared with reflect.DeepEqual, and a map key present in only one
+// of a and b has a nil Old or New value. Struct fields excluded by WithFieldFilter
+// are not compared, values of types with a transformer are compared as a whole,
+// and values that WithEmptyFunc reports as empty in both a and b are equal.
+// Like DeepMerge, Diff of pointers compares what they point to.
+func Diff(a, b any, opts ...Option) ([]Change, error) {
+	if a == nil || b == nil {
+		return nil, errors.New("a or b is nil")
+	}
+
+	var c Config
+	Options(opts).apply(&c)
+
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if va.Type() != vb.Type() {
+		return nil, errors.New(va.Type().String() + " != " + vb.Type().String())
+	}
+
+	var d differ
+	diffValues(va, vb, &d, &c)
+	return d.changes, nil
+}
+
+// Equal reports whether a and b are equal as Diff compares them, that is whether
+// Diff would report no changes. Unlike reflect.DeepEqual, it skips struct fields
+// excluded by WithFieldFilter and treats values that WithEmptyFunc reports as empty
+// as equal. It stops at the first difference. Values of different types are not equal.
+func Equal(a, b any, opts ...Option) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	var c Config
+	Options(opts).apply(&c)
+
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if va.Type() != vb.Type() {
+		return false
+	}
+
+	d := differ{first: true}
+	diffValues(va, vb, &d, &c)
+	return len(d.changes) == 0
+}
+
+// diffValues records the changes between a and b, or what they point to, in d.
+func diffValues(a, b reflect.Value, d *differ, c *Config) {
+	for reflect.Pointer == a.Kind() && !a.IsNil() && !b.IsNil() {
+		a, b = a.Elem(), b.Elem()
+	}
+	deepValueDiff("", a, b, make(map[diffVisit]bool), d, c)
+}
@@ -7,6 +7,9 @@ import "reflect"
 var DeepValueMerge = func(dst, src reflect.Value, opts ...Option) error {
 	var c Config
 	Options(opts).apply(&c)
+	if err := c.validate(); err != nil {
+		return err
+	}
 
 	return deepValueMerge("", dst, src, make(map[visit]string), &c)
 }
@@ -14,6 +17,17 @@ var DeepValueMerge = func(dst, src reflect.Value, opts ...Option) error {
 var DeepValueMap = func(dst, src reflect.Value, opts ...Option) error {
 	var c Config
 	Options(opts).apply(&c)
+	if err := c.validate(); err != nil {
+		return err
+	}
 
 	return deepValueMap("", dst, src, make(map[visit]string), &c)
 }
+
+// WithMapWriteHook registers f to be called after every write to a dst map.
+func WithMapWriteHook(f func(m, k, v reflect.Value)) Option {
+	return option(func(c *Config) { c.mapWriteHook = f })
+}
+
+// CanExposeUnexported reports whether WithUnexportedFields is available.
+const CanExposeUnexported = canExposeUnexported
@@ -0,0 +1,89 @@
+package merge
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// field describes a struct field as seen by deepValueMerge and deepValueMap.
+type field struct {
+	reflect.StructField
+
+	// mergeable reports whether the field takes part in merging: it is exported,
+	// a struct, or embedded.
+	mergeable bool
+
+	mergePath string    // suffix of the field path in deepValueMerge, e.g. ".Name"
+	mapPath   string    // suffix of the field path in deepValueMap, e.g. "[Name]"
+	keys      [3]string // map keys of the field indexed by KeyStyle
+
+	transform string // name of the transformer from the `merge:"transform=name"` tag
+	omitEmpty bool   // the field has the `merge:"omitempty"` tag
+}
+
+// structFields describes the fields of a struct type.
+type structFields struct {
+	list []field // the fields in declaration order
+
+	// mergeable reports whether some field in list is mergeable.
+	mergeable bool
+
+	// promoted lists the exported fields promoted from embedded structs
+	// that are neither shadowed by shallower fields nor ambiguous.
+	promoted []field
+}
+
+var fieldCache sync.Map // map[reflect.Type]*structFields
+
+// cachedFields returns the fields of struct type t, computing them
+// the first time t is seen.
+func cachedFields(t reflect.Type) *structFields {
+	if f, ok := fieldCache.Load(t); ok {
+		return f.(*structFields)
+	}
+	f, _ := fieldCache.LoadOrStore(t, typeFields(t))
+	return f.(*structFields)
+}
+
+func typeFields(t reflect.Type) *structFields {
+	newField := func(sf reflect.StructField) field {
+		f := field{
+			StructField: sf,
+			mergeable:   sf.IsExported() || reflect.Struct == sf.Type.Kind() || sf.Anonymous,
+			mergePath:   "." + sf.Name,
+			mapPath:     "[" + sf.Name + "]",
+		}
+		for _, d := range strings.Split(sf.Tag.Get("merge"), ",") {
+			if name, ok := strings.CutPrefix(d, "transform="); ok {
+				f.transform = name
+			}
+			if "omitempty" == d {
+				f.omitEmpty = true
+			}
+		}
+		for _, s := range []KeyStyle{KeyStyleCamel, KeyStyleSnake, KeyStyleExact} {
+			f.keys[s] = s.key(sf.Name)
+		}
+		return f
+	}
+
+	fs := &structFields{list: make([]field, t.NumField())}
+	for i := range fs.list {
+		fs.list[i] = newField(t.Field(i))
+		fs.mergeable = fs.mergeable || fs.list[i].mergeable
+	}
+
+	for _, sf := range reflect.VisibleFields(t) {
+		if len(sf.Index) == 1 || !sf.IsExported() {
+			continue
+		}
+
+		// Shadowed by a shallower field, or ambiguous.
+		if f, ok := t.FieldByName(sf.Name); !ok || !equalIndex(f.Index, sf.Index) {
+			continue
+		}
+		fs.promoted = append(fs.promoted, newField(sf))
+	}
+	return fs
+}
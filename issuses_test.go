@@ -246,6 +246,64 @@ func TestIssue61MergeNilMap(t *testing.T) {
 	t.Run("Map", func(t *testing.T) { testDeepMap(t, test) })
 }
 
+func TestIssue61MergeExistingMap(t *testing.T) {
+	t.Parallel()
+
+	type T struct {
+		S map[string][]int
+		M map[string]map[string]int
+		P map[string]*int
+		A map[string]any
+	}
+	src := T{
+		S: map[string][]int{"foo": {1, 2, 3}},
+		M: map[string]map[string]int{"foo": {"a": 1}},
+		P: map[string]*int{"foo": New(1)},
+		A: map[string]any{"foo": []int{1, 2, 3}, "bar": map[string]any{"a": 1}},
+	}
+	newDst := func() *T {
+		return &T{
+			S: map[string][]int{"dst": {4}},
+			M: map[string]map[string]int{"dst": {"b": 2}},
+			P: map[string]*int{"dst": New(2)},
+			A: map[string]any{"dst": 2},
+		}
+	}
+
+	test := test{
+		dst: newDst(),
+		src: src,
+		want: &T{
+			S: map[string][]int{"dst": {4}, "foo": {1, 2, 3}},
+			M: map[string]map[string]int{"dst": {"b": 2}, "foo": {"a": 1}},
+			P: map[string]*int{"dst": New(2), "foo": New(1)},
+			A: map[string]any{"dst": 2, "foo": []int{1, 2, 3}, "bar": map[string]any{"a": 1}},
+		},
+		check: func(t testing.TB, a any) {
+			dst := a.(*T)
+			for name, v := range map[string][2]any{
+				"slice":              {src.S["foo"], dst.S["foo"]},
+				"map":                {src.M["foo"], dst.M["foo"]},
+				"pointer":            {src.P["foo"], dst.P["foo"]},
+				"slice in interface": {src.A["foo"], dst.A["foo"]},
+				"map in interface":   {src.A["bar"], dst.A["bar"]},
+			} {
+				if fmt.Sprintf("%p", v[0]) == fmt.Sprintf("%p", v[1]) {
+					t.Errorf("dst and src %s shared", name)
+				}
+			}
+		},
+	}
+
+	t.Run("Merge", func(t *testing.T) { testDeepMerge(t, test) })
+
+	t.Run("Map", func(t *testing.T) {
+		test := test
+		test.dst = newDst()
+		testDeepMap(t, test)
+	})
+}
+
 func TestIssue64MergeSliceWithOverride(t *testing.T) {
 	t.Parallel()
 
@@ -396,7 +454,7 @@ func TestIssue89MergeWithEmptyValue(t *testing.T) {
 	test := test{
 		dst:       map[string]any{"A": 3, "B": "note", "C": true},
 		src:       map[string]any{"B": "", "C": false},
-		mergeOpts: Options{WithOverwriteWithEmptyValue()},
+		mergeOpts: Options{WithOverwriteWithEmptyValue(), WithPruneMissingKeys()},
 		want:      map[string]any{"B": "", "C": false},
 	}
 
@@ -839,7 +897,7 @@ func TestMergeMapWithOverwrite(t *testing.T) {
 		{
 			dst:       map[string]int{"a": 1, "b": 2},
 			src:       map[string]int{"a": 1, "c": 3},
-			mergeOpts: Options{WithOverwriteWithEmptyValue()},
+			mergeOpts: Options{WithOverwriteWithEmptyValue(), WithPruneMissingKeys()},
 			want:      map[string]int{"a": 1, "c": 3},
 		},
 		{
@@ -852,13 +910,13 @@ func TestMergeMapWithOverwrite(t *testing.T) {
 		{
 			dst:       map[string]int{"a": 1, "b": 2},
 			src:       map[string]int{},
-			mergeOpts: Options{WithOverwriteWithEmptyValue()},
+			mergeOpts: Options{WithOverwriteWithEmptyValue(), WithPruneMissingKeys()},
 			want:      map[string]int{},
 		},
 		{
 			dst:       map[string]int{"a": 1, "b": 2},
 			src:       map[string]int(nil),
-			mergeOpts: Options{WithOverwriteWithEmptyValue()},
+			mergeOpts: Options{WithOverwriteWithEmptyValue(), WithPruneMissingKeys()},
 			want:      map[string]int{},
 		},
 
@@ -874,6 +932,25 @@ func TestMergeMapWithOverwrite(t *testing.T) {
 			mergeOpts: Options{WithOverwrite()},
 			want:      map[string]int{"a": 1, "b": 2},
 		},
+
+		{
+			dst:       map[string]int{"a": 1, "b": 2},
+			src:       map[string]int{"a": 0, "c": 3},
+			mergeOpts: Options{WithOverwriteWithEmptyValue()},
+			want:      map[string]int{"a": 0, "b": 2, "c": 3},
+		},
+		{
+			dst:       map[string]int{"a": 1, "b": 2},
+			src:       map[string]int{"a": 0, "c": 3},
+			mergeOpts: Options{WithPruneMissingKeys()},
+			want:      map[string]int{"a": 1, "c": 3},
+		},
+		{
+			dst:       map[string]int{"a": 1, "b": 2},
+			src:       map[string]int{"a": 0, "c": 3},
+			mergeOpts: Options{WithOverwriteWithEmptyValue(), WithPruneMissingKeys()},
+			want:      map[string]int{"a": 0, "c": 3},
+		},
 	}
 
 	t.Run("Merge", func(t *testing.T) { testDeepMerge(t, tests...) })
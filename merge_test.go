@@ -6,7 +6,16 @@
 package merge_test
 
 import (
+	"database/sql"
+	"errors"
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	. "github.com/weiwenchen2022/merge"
 
@@ -69,3 +78,2760 @@ func TestMapNonPointer(t *testing.T) {
 
 	t.Run("Map", func(t *testing.T) { testDeepMap(t, test) })
 }
+
+func TestMergeWithPathTransformer(t *testing.T) {
+	t.Parallel()
+
+	type Metadata struct {
+		Created string
+		Updated string
+	}
+	type T struct {
+		Metadata Metadata
+	}
+	transformAt := func(want string) Option {
+		return WithPathTransformer(func(path string, dst *string, src string) error {
+			if path == want {
+				*dst = "transformed " + src
+			}
+			return nil
+		})
+	}
+	test := test{
+		dst:  &T{},
+		src:  T{Metadata{"created", "updated"}},
+		want: &T{Metadata{Created: "transformed created"}},
+	}
+
+	t.Run("Merge", func(t *testing.T) {
+		test := test
+		test.mergeOpts = Options{transformAt(".Metadata.Created")}
+		testDeepMerge(t, test)
+	})
+
+	t.Run("Map", func(t *testing.T) {
+		test := test
+		test.mergeOpts = Options{transformAt("[Metadata][Created]")}
+		testDeepMap(t, test)
+	})
+}
+
+func TestMust(t *testing.T) {
+	t.Parallel()
+
+	mustPanic := func(t *testing.T, f func()) {
+		t.Helper()
+		defer func() {
+			if recover() == nil {
+				t.Error("want panic got nil")
+			}
+		}()
+		f()
+	}
+
+	dst := &T{}
+	MustMerge(dst, T{42})
+	if dst.A != 42 {
+		t.Errorf("MustMerge: got %d, want 42", dst.A)
+	}
+
+	dst = &T{}
+	MustMap(dst, map[string]any{"a": 42})
+	if dst.A != 42 {
+		t.Errorf("MustMap: got %d, want 42", dst.A)
+	}
+
+	mustPanic(t, func() { MustMerge(T{}, T{42}) })
+	mustPanic(t, func() { MustMap(T{}, T{42}) })
+}
+
+func TestTyped(t *testing.T) {
+	t.Parallel()
+
+	type S struct {
+		A int
+		B string
+		P *int
+	}
+
+	dst := S{A: 1}
+	if err := MergeTyped(&dst, S{A: 2, B: "src", P: New(3)}); err != nil {
+		t.Fatal(err)
+	}
+	if want := (S{A: 1, B: "src", P: New(3)}); !cmp.Equal(want, dst) {
+		t.Errorf("MergeTyped: got %+v, want %+v", dst, want)
+	}
+
+	dst = S{A: 1}
+	if err := MapTyped(&dst, S{A: 2, B: "src"}, WithOverwrite()); err != nil {
+		t.Fatal(err)
+	}
+	if want := (S{A: 2, B: "src"}); !cmp.Equal(want, dst) {
+		t.Errorf("MapTyped: got %+v, want %+v", dst, want)
+	}
+
+	p := New(1)
+	if err := MergeTyped(&p, New(2), WithOverwrite()); err != nil {
+		t.Fatal(err)
+	}
+	if *p != 2 {
+		t.Errorf("MergeTyped pointer: got %d, want 2", *p)
+	}
+
+	var iface any
+	if err := MergeTyped(&iface, any(nil)); err != nil {
+		t.Errorf("MergeTyped nil interface: %v", err)
+	}
+
+	if err := MergeTyped(nil, S{}); err == nil {
+		t.Error("MergeTyped nil dst: want error got nil")
+	}
+	if err := MapTyped(nil, S{}); err == nil {
+		t.Error("MapTyped nil dst: want error got nil")
+	}
+}
+
+func TestMergeInto(t *testing.T) {
+	t.Parallel()
+
+	type Server struct {
+		Host string
+		Port int
+	}
+	type Config struct {
+		Name   string
+		Tags   []string
+		Server Server
+	}
+
+	dst := Config{Name: "dst", Tags: []string{"a"}, Server: Server{Host: "localhost", Port: 80}}
+	src := Config{Name: "", Tags: []string{"b", "c"}, Server: Server{Host: "example.com", Port: 8080}}
+	err := MergeInto(&dst, src,
+		func(c *Config) any { return &c.Tags },
+		func(c *Config) any { return &c.Server.Port },
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Config{Name: "dst", Tags: []string{"b", "c"}, Server: Server{Host: "localhost", Port: 8080}}
+	if !cmp.Equal(want, dst) {
+		t.Error(cmp.Diff(want, dst))
+	}
+
+	// The first field of a struct shares its address.
+	dst = Config{Name: "dst", Server: Server{Port: 80}}
+	if err := MergeInto(&dst, src, func(c *Config) any { return &c.Server.Host }); err != nil {
+		t.Fatal(err)
+	}
+	if want := (Config{Name: "dst", Server: Server{Host: "example.com", Port: 80}}); !cmp.Equal(want, dst) {
+		t.Error(cmp.Diff(want, dst))
+	}
+	if err := MergeInto(&dst, src, func(c *Config) any { return &c.Server }); err != nil {
+		t.Fatal(err)
+	}
+	if want := (Config{Name: "dst", Server: src.Server}); !cmp.Equal(want, dst) {
+		t.Error(cmp.Diff(want, dst))
+	}
+
+	// Empty src fields are set too.
+	dst = Config{Name: "dst", Server: Server{Host: "localhost", Port: 80}}
+	err = MergeInto(&dst, Config{Server: Server{Host: "example.com"}},
+		func(c *Config) any { return &c.Name },
+		func(c *Config) any { return &c.Server },
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Config{Server: Server{Host: "example.com"}}); !cmp.Equal(want, dst) {
+		t.Error("empty src:", cmp.Diff(want, dst))
+	}
+
+	dst = Config{Name: "dst", Server: src.Server}
+	if err := MergeInto(&dst, src); err != nil || dst.Name != "dst" {
+		t.Errorf("no fields: got %q, %v, want dst untouched", dst.Name, err)
+	}
+
+	other := new(int)
+	dst.Tags = []string{"a"}
+	for name, sel := range map[string]func(*Config) any{
+		"not a pointer": func(c *Config) any { return c.Name },
+		"not in dst":    func(c *Config) any { return other },
+		"element":       func(c *Config) any { return &c.Tags[0] },
+	} {
+		if err := MergeInto(&dst, src, sel); err == nil {
+			t.Errorf("%s: want error got nil", name)
+		}
+	}
+	if err := MergeInto(nil, src); err == nil {
+		t.Error("nil dst: want error got nil")
+	}
+
+	// A bad selector after a good one leaves dst untouched.
+	dst = Config{Name: "dst", Server: Server{Port: 80}}
+	err = MergeInto(&dst, src,
+		func(c *Config) any { return &c.Server.Port },
+		func(c *Config) any { return other },
+	)
+	if err == nil {
+		t.Error("bad selector: want error got nil")
+	}
+	if want := (Config{Name: "dst", Server: Server{Port: 80}}); !cmp.Equal(want, dst) {
+		t.Error("bad selector:", cmp.Diff(want, dst))
+	}
+}
+
+func TestMergeWithSkipUnchangedMapWrites(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		mergeOpts Options
+		want      []string
+	}{
+		{"default", Options{WithOverwrite()}, []string{"a", "b", "c"}},
+		{"skip unchanged", Options{WithOverwrite(), WithSkipUnchangedMapWrites()}, []string{"b", "c"}},
+	}
+
+	testMergeFuncs(t, func(t *testing.T, merge mergeFunc) {
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				var writes []string
+				hook := WithMapWriteHook(func(_, k, _ reflect.Value) {
+					writes = append(writes, k.String())
+				})
+
+				dst := map[string]any{"a": 1, "b": 2}
+				src := map[string]any{"a": 1, "b": 3, "c": 4}
+				if err := merge(dst, src, append(tt.mergeOpts, hook)...); err != nil {
+					t.Fatal(err)
+				}
+
+				if want := map[string]any{"a": 1, "b": 3, "c": 4}; !cmp.Equal(want, dst) {
+					t.Error(cmp.Diff(want, dst))
+				}
+				sort.Strings(writes)
+				if !cmp.Equal(tt.want, writes) {
+					t.Error(cmp.Diff(tt.want, writes))
+				}
+			})
+		}
+	})
+}
+
+func TestMergeWithFieldFilter(t *testing.T) {
+	t.Parallel()
+
+	type Inner struct {
+		A, B string
+	}
+	type T struct {
+		A, B  string
+		Inner Inner
+	}
+
+	// Skip T.B and T.Inner.A; both engines pass the same paths.
+	skip := map[string]bool{".B": true, ".Inner.A": true}
+	filter := func(path string, _ reflect.StructField) bool { return !skip[path] }
+
+	test := test{
+		dst:       &T{},
+		src:       T{"a", "b", Inner{"a", "b"}},
+		mergeOpts: Options{WithFieldFilter(filter)},
+		want:      &T{A: "a", Inner: Inner{B: "b"}},
+	}
+
+	t.Run("Merge", func(t *testing.T) { testDeepMerge(t, test) })
+
+	t.Run("Map", func(t *testing.T) { testDeepMap(t, test) })
+
+	t.Run("Paths", func(t *testing.T) {
+		type U struct {
+			P *Inner
+			M map[string]Inner
+		}
+
+		paths := func(merge func(*[]string) error) []string {
+			var got []string
+			if err := merge(&got); err != nil {
+				t.Fatal(err)
+			}
+			sort.Strings(got)
+			return got
+		}
+		record := func(got *[]string) Option {
+			return WithFieldFilter(func(path string, _ reflect.StructField) bool {
+				*got = append(*got, path)
+				return true
+			})
+		}
+		src := U{P: &Inner{"a", "b"}, M: map[string]Inner{"k": {"a", "b"}}}
+
+		mergePaths := paths(func(got *[]string) error {
+			return DeepMerge(&U{}, src, record(got))
+		})
+		mapPaths := paths(func(got *[]string) error {
+			return DeepMap(&U{}, map[string]any{
+				"P": map[string]any{"A": "a", "B": "b"},
+				"M": map[string]Inner{"k": {"a", "b"}},
+			}, record(got))
+		})
+
+		want := []string{"(*.P).A", "(*.P).B", ".M", ".M[k].A", ".M[k].B", ".P"}
+		if !reflect.DeepEqual(want, mergePaths) {
+			t.Errorf("Merge paths = %q, want %q", mergePaths, want)
+		}
+		if !reflect.DeepEqual(want, mapPaths) {
+			t.Errorf("Map paths = %q, want %q", mapPaths, want)
+		}
+	})
+}
+
+func TestMergeWithOptionsAt(t *testing.T) {
+	t.Parallel()
+
+	type Config struct {
+		Ports []int
+		Host  string
+	}
+	type T struct {
+		Tag    string
+		Tags   []string
+		Config *Config
+		Labels map[string]any
+	}
+
+	newDst := func() *T {
+		return &T{
+			Tag:    "dst",
+			Tags:   []string{"a"},
+			Config: &Config{Ports: []int{80, 443}, Host: "dst"},
+			Labels: map[string]any{"x": "dst", "y": "dst"},
+		}
+	}
+	src := T{
+		Tag:    "src",
+		Tags:   []string{"b"},
+		Config: &Config{Ports: []int{8080}, Host: "src"},
+		Labels: map[string]any{"x": "src", "y": "src"},
+	}
+
+	// tests returns the tests with paths formatted by field, which
+	// returns the path selecting a field in the syntax of each engine.
+	tests := func(field func(name string) string) []test {
+		return []test{
+			{
+				name: "scoped",
+				dst:  newDst(),
+				src:  src,
+				mergeOpts: Options{
+					WithOptionsAt(field("Tags"), WithAppendSlice()),
+					WithOptionsAt(field("Config")+field("Ports"), WithOverwrite(), WithSliceTruncate()),
+					WithOptionsAt(field("Labels")+"[x]", WithOverwrite()),
+				},
+				want: &T{
+					Tag:    "dst",
+					Tags:   []string{"a", "b"},
+					Config: &Config{Ports: []int{8080}, Host: "dst"},
+					Labels: map[string]any{"x": "src", "y": "dst"},
+				},
+			},
+			{
+				name: "whole selector",
+				dst:  newDst(),
+				src:  src,
+				mergeOpts: Options{
+					WithOptionsAt(field("Tag"), WithOverwrite()),
+				},
+				want: &T{
+					Tag:    "src",
+					Tags:   []string{"a"},
+					Config: &Config{Ports: []int{80, 443}, Host: "dst"},
+					Labels: map[string]any{"x": "dst", "y": "dst"},
+				},
+			},
+			{
+				name:      "root",
+				dst:       newDst(),
+				src:       src,
+				mergeOpts: Options{WithOptionsAt("", WithOverwrite())},
+				want: &T{
+					Tag:    "src",
+					Tags:   []string{"b"},
+					Config: &Config{Ports: []int{8080, 443}, Host: "src"},
+					Labels: map[string]any{"x": "src", "y": "src"},
+				},
+			},
+			{
+				name:      "invalid",
+				dst:       newDst(),
+				src:       src,
+				mergeOpts: Options{WithOptionsAt(field("Config"), WithTypeCheck())},
+				wantErr:   true,
+			},
+		}
+	}
+
+	t.Run("Merge", func(t *testing.T) {
+		testDeepMerge(t, tests(func(name string) string { return "." + name })...)
+	})
+
+	t.Run("Map", func(t *testing.T) {
+		testDeepMap(t, tests(func(name string) string { return "[" + name + "]" })...)
+	})
+}
+
+func TestOptionValidation(t *testing.T) {
+	t.Parallel()
+
+	tests := []test{
+		{
+			name:      "WithTypeCheck without WithOverwrite",
+			dst:       &T{},
+			src:       T{42},
+			mergeOpts: Options{WithTypeCheck()},
+			wantErr:   true,
+		},
+		{
+			name:      "WithFloatTolerance negative",
+			dst:       &T{},
+			src:       T{42},
+			mergeOpts: Options{WithFloatTolerance(-1)},
+			wantErr:   true,
+		},
+		{
+			name:      "WithFloatTolerance NaN",
+			dst:       &T{},
+			src:       T{42},
+			mergeOpts: Options{WithFloatTolerance(math.NaN())},
+			wantErr:   true,
+		},
+		{
+			name:      "WithOverwrite with WithDefaultsOnly",
+			dst:       &T{},
+			src:       T{42},
+			mergeOpts: Options{WithOverwrite(), WithDefaultsOnly()},
+			wantErr:   true,
+		},
+		{
+			name:      "WithPrependSlice with WithAppendSlice",
+			dst:       &T{},
+			src:       T{42},
+			mergeOpts: Options{WithPrependSlice(), WithAppendSlice()},
+			wantErr:   true,
+		},
+		{
+			name:      "WithPrependSlice with a slice strategy",
+			dst:       &T{},
+			src:       T{42},
+			mergeOpts: Options{WithPrependSlice(), WithSliceStrategy(SliceAppendUnique)},
+			wantErr:   true,
+		},
+		{
+			name:      "WithPreferLongerSlice with WithPrependSlice",
+			dst:       &T{},
+			src:       T{42},
+			mergeOpts: Options{WithPreferLongerSlice(), WithPrependSlice()},
+			wantErr:   true,
+		},
+		{
+			name:      "WithPreferLongerSlice with a slice strategy",
+			dst:       &T{},
+			src:       T{42},
+			mergeOpts: Options{WithPreferLongerSlice(), WithSliceTruncate()},
+			wantErr:   true,
+		},
+	}
+	if !CanExposeUnexported {
+		tests = append(tests, test{
+			name:      "WithUnexportedFields with nounsafe",
+			dst:       &T{},
+			src:       T{42},
+			mergeOpts: Options{WithUnexportedFields()},
+			wantErr:   true,
+		})
+	}
+
+	t.Run("Merge", func(t *testing.T) { testDeepMerge(t, tests...) })
+
+	t.Run("Map", func(t *testing.T) { testDeepMap(t, tests...) })
+}
+
+func TestMergeWithPostProcess(t *testing.T) {
+	t.Parallel()
+
+	type Rect struct {
+		W, H int
+		Area int
+	}
+	type T struct {
+		Rects map[string]Rect
+	}
+
+	area := WithPostProcess(reflect.TypeOf(Rect{}), func(v reflect.Value) error {
+		r := v.Addr().Interface().(*Rect)
+		r.Area = r.W * r.H
+		return nil
+	})
+
+	tests := []test{
+		{
+			dst:       &Rect{W: 2},
+			src:       Rect{W: 4, H: 3, Area: 12},
+			mergeOpts: Options{area},
+			want:      &Rect{W: 2, H: 3, Area: 6},
+		},
+		{
+			dst:       &T{map[string]Rect{"a": {W: 2}}},
+			src:       T{map[string]Rect{"a": {H: 3}, "b": {W: 1, H: 1}}},
+			mergeOpts: Options{area},
+			want:      &T{map[string]Rect{"a": {W: 2, H: 3, Area: 6}, "b": {W: 1, H: 1, Area: 1}}},
+		},
+	}
+
+	t.Run("Merge", func(t *testing.T) { testDeepMerge(t, tests...) })
+
+	t.Run("Map", func(t *testing.T) { testDeepMap(t, tests...) })
+}
+
+func TestMergeWithTimeTransformer(t *testing.T) {
+	t.Parallel()
+
+	type T struct{ Created time.Time }
+	now := time.Now()
+	later := now.Add(time.Hour)
+
+	tests := []test{
+		{
+			dst:       &T{now},
+			src:       T{},
+			mergeOpts: Options{WithOverwrite(), WithTimeTransformer(true)},
+			want:      &T{now},
+		},
+		{
+			dst:       &T{},
+			src:       T{now},
+			mergeOpts: Options{WithTimeTransformer(false)},
+			want:      &T{now},
+		},
+		{
+			dst:       &T{now},
+			src:       T{later},
+			mergeOpts: Options{WithTimeTransformer(false)},
+			want:      &T{now},
+		},
+		{
+			dst:       &T{now},
+			src:       T{later},
+			mergeOpts: Options{WithOverwrite(), WithTimeTransformer(true)},
+			want:      &T{later},
+		},
+	}
+
+	t.Run("Merge", func(t *testing.T) { testDeepMerge(t, tests...) })
+
+	t.Run("Map", func(t *testing.T) { testDeepMap(t, tests...) })
+}
+
+func TestMergeWithTransformerWithoutDereference(t *testing.T) {
+	t.Parallel()
+
+	type T struct{ Created *time.Time }
+	now := time.Now()
+	later := now.Add(time.Hour)
+
+	// Keep the later time, which WithOverwrite alone would not.
+	latest := WithTransformer(func(dst *time.Time, src time.Time) error {
+		if src.After(*dst) {
+			*dst = src
+		}
+		return nil
+	})
+	opts := Options{WithoutDereference(), WithOverwrite(), latest}
+
+	tests := func() []test {
+		src := &now
+		notAliased := func(t testing.TB, dst any) {
+			if dst.(*T).Created == src {
+				t.Error("dst aliases src")
+			}
+		}
+
+		return []test{
+			{
+				name:      "later dst",
+				dst:       &T{New(later)},
+				src:       T{src},
+				mergeOpts: opts,
+				want:      &T{&later},
+				check:     notAliased,
+			},
+			{
+				name:      "earlier dst",
+				dst:       &T{New(now.Add(-time.Hour))},
+				src:       T{src},
+				mergeOpts: opts,
+				want:      &T{&now},
+				check:     notAliased,
+			},
+			{
+				name:      "nil dst",
+				dst:       &T{},
+				src:       T{src},
+				mergeOpts: opts,
+				want:      &T{&now},
+				check:     notAliased,
+			},
+			{
+				name:      "nil src",
+				dst:       &T{New(later)},
+				src:       T{},
+				mergeOpts: opts,
+				want:      &T{&later},
+			},
+		}
+	}
+
+	t.Run("Merge", func(t *testing.T) { testDeepMerge(t, tests()...) })
+
+	t.Run("Map", func(t *testing.T) { testDeepMap(t, tests()...) })
+}
+
+func TestMergeWithBigNumberSupport(t *testing.T) {
+	t.Parallel()
+
+	type T struct {
+		I *big.Int
+		F *big.Float
+		R *big.Rat
+	}
+
+	src := T{I: big.NewInt(42), F: big.NewFloat(1.5), R: big.NewRat(1, 3)}
+	cmpOpts := cmp.Options{
+		cmp.Comparer(func(x, y *big.Int) bool { return x == nil && y == nil || x != nil && y != nil && x.Cmp(y) == 0 }),
+		cmp.Comparer(func(x, y *big.Float) bool { return x == nil && y == nil || x != nil && y != nil && x.Cmp(y) == 0 }),
+		cmp.Comparer(func(x, y *big.Rat) bool { return x == nil && y == nil || x != nil && y != nil && x.Cmp(y) == 0 }),
+	}
+	noAlias := func(t testing.TB, dst any) {
+		d := dst.(*T)
+		if d.I == src.I || d.F == src.F || d.R == src.R {
+			t.Error("dst aliases src")
+		}
+	}
+
+	tests := func(field func(string) string) []test {
+		return []test{
+			{
+				name:      "nil dst",
+				dst:       &T{},
+				src:       src,
+				mergeOpts: Options{WithBigNumberSupport()},
+				want:      &T{I: big.NewInt(42), F: big.NewFloat(1.5), R: big.NewRat(1, 3)},
+				cmpOpts:   cmpOpts,
+				check:     noAlias,
+			},
+			{
+				name:      "zero dst",
+				dst:       &T{I: new(big.Int), F: new(big.Float), R: new(big.Rat)},
+				src:       src,
+				mergeOpts: Options{WithBigNumberSupport()},
+				want:      &T{I: big.NewInt(42), F: big.NewFloat(1.5), R: big.NewRat(1, 3)},
+				cmpOpts:   cmpOpts,
+				check:     noAlias,
+			},
+			{
+				name:      "non-zero dst",
+				dst:       &T{I: big.NewInt(1), F: big.NewFloat(2), R: big.NewRat(3, 4)},
+				src:       src,
+				mergeOpts: Options{WithBigNumberSupport()},
+				want:      &T{I: big.NewInt(1), F: big.NewFloat(2), R: big.NewRat(3, 4)},
+				cmpOpts:   cmpOpts,
+			},
+			{
+				name:      "overwrite",
+				dst:       &T{I: big.NewInt(1), F: big.NewFloat(2), R: big.NewRat(3, 4)},
+				src:       src,
+				mergeOpts: Options{WithBigNumberSupport(), WithOverwrite()},
+				want:      &T{I: big.NewInt(42), F: big.NewFloat(1.5), R: big.NewRat(1, 3)},
+				cmpOpts:   cmpOpts,
+				check:     noAlias,
+			},
+			{
+				name:      "empty src",
+				dst:       &T{I: big.NewInt(1), F: big.NewFloat(2), R: big.NewRat(3, 4)},
+				src:       T{I: new(big.Int)},
+				mergeOpts: Options{WithBigNumberSupport(), WithOverwrite()},
+				want:      &T{I: big.NewInt(1), F: big.NewFloat(2), R: big.NewRat(3, 4)},
+				cmpOpts:   cmpOpts,
+			},
+			{
+				name:      "overwrite with empty value",
+				dst:       &T{I: big.NewInt(1)},
+				src:       T{I: new(big.Int)},
+				mergeOpts: Options{WithBigNumberSupport(), WithOverwriteWithEmptyValue()},
+				want:      &T{I: new(big.Int)},
+				cmpOpts:   cmpOpts,
+			},
+			{
+				name:      "scoped overwrite",
+				dst:       &T{I: big.NewInt(1), F: big.NewFloat(2), R: big.NewRat(3, 4)},
+				src:       src,
+				mergeOpts: Options{WithBigNumberSupport(), WithOptionsAt(field("I"), WithOverwrite())},
+				want:      &T{I: big.NewInt(42), F: big.NewFloat(2), R: big.NewRat(3, 4)},
+				cmpOpts:   cmpOpts,
+			},
+		}
+	}
+
+	t.Run("Merge", func(t *testing.T) {
+		testDeepMerge(t, tests(func(name string) string { return "." + name })...)
+	})
+
+	t.Run("Map", func(t *testing.T) {
+		testDeepMap(t, tests(func(name string) string { return "[" + name + "]" })...)
+	})
+
+	if src.I.Int64() != 42 {
+		t.Errorf("src modified: %v", src.I)
+	}
+}
+
+func TestMergeWithAssertIdempotent(t *testing.T) {
+	t.Parallel()
+
+	type T struct {
+		A int
+		S []int
+		M map[string]string
+	}
+
+	newDst := func() *T { return &T{S: []int{1}, M: map[string]string{"a": "x"}} }
+	src := T{A: 1, S: []int{2}, M: map[string]string{"b": "y"}}
+
+	tests := []test{
+		{
+			name:      "default",
+			dst:       newDst(),
+			src:       src,
+			mergeOpts: Options{WithAssertIdempotent()},
+			want:      &T{A: 1, S: []int{1}, M: map[string]string{"a": "x", "b": "y"}},
+		},
+		{
+			name:      "overwrite",
+			dst:       newDst(),
+			src:       src,
+			mergeOpts: Options{WithOverwrite(), WithAssertIdempotent()},
+			want:      &T{A: 1, S: []int{2}, M: map[string]string{"a": "x", "b": "y"}},
+		},
+		{
+			name:      "append slice",
+			dst:       newDst(),
+			src:       src,
+			mergeOpts: Options{WithAppendSlice(), WithAssertIdempotent()},
+			wantErr:   true,
+		},
+	}
+
+	t.Run("Merge", func(t *testing.T) { testDeepMerge(t, tests...) })
+
+	t.Run("Map", func(t *testing.T) { testDeepMap(t, tests...) })
+
+	t.Run("NumericAdd", func(t *testing.T) {
+		testDeepMerge(t, test{
+			dst:       newDst(),
+			src:       src,
+			mergeOpts: Options{WithNumericAdd(), WithAssertIdempotent()},
+			wantErr:   true,
+		})
+	})
+
+	t.Run("FuncField", func(t *testing.T) {
+		type F struct {
+			A  int
+			Fn func() int
+		}
+
+		fn := func() int { return 1 }
+		testDeepMerge(t, test{
+			dst:       &F{},
+			src:       F{A: 1, Fn: fn},
+			mergeOpts: Options{WithAssertIdempotent()},
+			check: func(t testing.TB, dst any) {
+				if f := dst.(*F); f.A != 1 || f.Fn == nil {
+					t.Errorf("got %+v", f)
+				}
+			},
+		})
+	})
+
+	t.Run("Observers", func(t *testing.T) {
+		var changes []Change
+		var events, hooks int
+		opts := Options{
+			WithOverwrite(),
+			WithDryRun(&changes),
+			WithTracer(func(TraceEvent) { events++ }),
+			WithHooks(func(string, reflect.Value, reflect.Value) { hooks++ }, nil),
+		}
+		if err := DeepMerge(newDst(), src, opts...); err != nil {
+			t.Fatal(err)
+		}
+		wantChanges, wantEvents, wantHooks := len(changes), events, hooks
+
+		changes, events, hooks = nil, 0, 0
+		if err := DeepMerge(newDst(), src, append(opts, WithAssertIdempotent())...); err != nil {
+			t.Fatal(err)
+		}
+		if len(changes) != wantChanges || events != wantEvents || hooks != wantHooks {
+			t.Errorf("got %d changes, %d events, %d hooks; want %d, %d, %d",
+				len(changes), events, hooks, wantChanges, wantEvents, wantHooks)
+		}
+	})
+}
+
+func TestApplyAndDiff(t *testing.T) {
+	t.Parallel()
+
+	type Inner struct {
+		N int
+		T time.Time
+	}
+	type T struct {
+		A     string
+		B     int
+		Inner *Inner
+		Items []int
+		M     map[string]int
+	}
+
+	sortChanges := func(changes []Change) {
+		sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	}
+
+	t.Run("struct", func(t *testing.T) {
+		t.Parallel()
+
+		now := time.Now()
+		dst := &T{A: "foo", Inner: &Inner{N: 1}, Items: []int{1, 2}}
+		src := T{A: "bar", B: 1, Inner: &Inner{N: 2, T: now}, Items: []int{3}}
+
+		changes, err := ApplyAndDiff(dst, src, WithOverwrite())
+		if err != nil {
+			t.Fatal(err)
+		}
+		sortChanges(changes)
+
+		want := []Change{
+			{"(*.Inner).N", 1, 2},
+			{"(*.Inner).T", time.Time{}, now},
+			{".A", "foo", "bar"},
+			{".B", 0, 1},
+			{".Items[0]", 1, 3},
+		}
+		if !cmp.Equal(want, changes) {
+			t.Error(cmp.Diff(want, changes))
+		}
+		if want := (&T{A: "bar", B: 1, Inner: &Inner{N: 2, T: now}, Items: []int{3, 2}}); !cmp.Equal(want, dst) {
+			t.Error(cmp.Diff(want, dst))
+		}
+	})
+
+	t.Run("map", func(t *testing.T) {
+		t.Parallel()
+
+		dst := map[string]any{"a": 1, "b": "foo", "m": map[string]int{"x": 1}}
+		src := map[string]any{"a": 2, "c": true, "m": map[string]int{"x": 2, "y": 3}}
+
+		changes, err := ApplyAndDiff(dst, src, WithOverwrite())
+		if err != nil {
+			t.Fatal(err)
+		}
+		sortChanges(changes)
+
+		want := []Change{
+			{"[a](interface {})", 1, 2},
+			{"[c]", nil, true},
+			{"[m](interface {})[x]", 1, 2},
+			{"[m](interface {})[y]", nil, 3},
+		}
+		if !cmp.Equal(want, changes) {
+			t.Error(cmp.Diff(want, changes))
+		}
+	})
+
+	t.Run("unchanged", func(t *testing.T) {
+		t.Parallel()
+
+		dst := &T{A: "foo", M: map[string]int{"a": 1}}
+		changes, err := ApplyAndDiff(dst, T{A: "bar", M: map[string]int{"a": 2}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(changes) != 0 {
+			t.Errorf("got changes %v, want none", changes)
+		}
+	})
+}
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+
+	type Inner struct {
+		N int
+		T time.Time
+	}
+	type T struct {
+		A     string
+		B     int
+		Inner *Inner
+		Items []int
+		M     map[string]any
+	}
+
+	now := time.Now()
+	a := &T{A: "foo", Inner: &Inner{N: 1}, Items: []int{1, 2}, M: map[string]any{"x": 1, "y": 2}}
+	b := &T{A: "bar", Inner: &Inner{N: 1, T: now}, Items: []int{1, 3}, M: map[string]any{"x": 1, "z": 3}}
+
+	tests := []struct {
+		name string
+		opts Options
+		want []Change
+	}{
+		{
+			name: "all",
+			want: []Change{
+				{".A", "foo", "bar"},
+				{"(*.Inner).T", time.Time{}, now},
+				{".Items[1]", 2, 3},
+				{".M[y]", 2, nil},
+				{".M[z]", nil, 3},
+			},
+		},
+		{
+			name: "field filter",
+			opts: Options{WithFieldFilter(func(path string, field reflect.StructField) bool {
+				return field.Name != "M" && path != "(*.Inner).T"
+			})},
+			want: []Change{
+				{".A", "foo", "bar"},
+				{".Items[1]", 2, 3},
+			},
+		},
+		{
+			name: "transformer",
+			opts: Options{WithTransformer(func(dst *[]int, src []int) error { return nil })},
+			want: []Change{
+				{".A", "foo", "bar"},
+				{"(*.Inner).T", time.Time{}, now},
+				{".Items", []int{1, 2}, []int{1, 3}},
+				{".M[y]", 2, nil},
+				{".M[z]", nil, 3},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes, err := Diff(a, b, append(tt.opts, WithSortedMapKeys())...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !cmp.Equal(tt.want, changes) {
+				t.Error(cmp.Diff(tt.want, changes))
+			}
+		})
+	}
+
+	t.Run("equal", func(t *testing.T) {
+		changes, err := Diff(a, a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(changes) != 0 {
+			t.Errorf("got changes %v, want none", changes)
+		}
+	})
+
+	t.Run("different types", func(t *testing.T) {
+		if _, err := Diff(1, "1"); err == nil {
+			t.Error("want error got nil")
+		}
+	})
+
+	t.Run("after merge", func(t *testing.T) {
+		dst := &T{A: "foo", B: 1}
+		if err := DeepMerge(dst, T{A: "bar", B: 2}, WithOverwrite()); err != nil {
+			t.Fatal(err)
+		}
+		changes, err := Diff(dst, &T{A: "bar", B: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(changes) != 0 {
+			t.Errorf("merge result differs from intended: %v", changes)
+		}
+	})
+
+	t.Run("pointers seen in other pairs", func(t *testing.T) {
+		type E struct{ P, Q, R *int }
+		x, y, z, w := New(1), New(1), New(2), New(2)
+		changes, err := Diff(E{x, z, x}, E{y, w, w})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []Change{{"(*.R)", 1, 2}}; !cmp.Equal(want, changes) {
+			t.Error(cmp.Diff(want, changes))
+		}
+	})
+}
+
+func TestEqual(t *testing.T) {
+	t.Parallel()
+
+	type T struct {
+		A    string
+		B    sql.NullString
+		Skip int
+		M    map[string][]int
+	}
+
+	skip := WithFieldFilter(func(path string, field reflect.StructField) bool { return field.Name != "Skip" })
+	nullEmpty := WithEmptyFunc(func(v reflect.Value) bool {
+		if ns, ok := v.Interface().(sql.NullString); ok {
+			return !ns.Valid
+		}
+		return v.IsZero()
+	})
+
+	a := &T{A: "foo", B: sql.NullString{String: "x"}, Skip: 1, M: map[string][]int{"k": {1, 2}}}
+
+	// Each pointer of the pair (z, w) is compared in another pair first.
+	type E struct{ P, Q, R *int }
+	x, y, z, w := New(1), New(1), New(2), New(2)
+	e1, e2 := E{x, z, x}, E{y, w, w}
+
+	tests := []struct {
+		name string
+		a, b any
+		opts Options
+		want bool
+	}{
+		{"same", a, &T{A: "foo", B: sql.NullString{String: "x"}, Skip: 1, M: map[string][]int{"k": {1, 2}}}, nil, true},
+		{"different", a, &T{A: "foo", B: sql.NullString{String: "x"}, Skip: 1, M: map[string][]int{"k": {1, 3}}}, nil, false},
+		{"filtered field", a, &T{A: "foo", B: sql.NullString{String: "x"}, Skip: 2, M: map[string][]int{"k": {1, 2}}}, Options{skip}, true},
+		{"filtered field without filter", a, &T{A: "foo", B: sql.NullString{String: "x"}, Skip: 2, M: map[string][]int{"k": {1, 2}}}, nil, false},
+		{"empty func", a, &T{A: "foo", B: sql.NullString{String: "y"}, Skip: 1, M: map[string][]int{"k": {1, 2}}}, Options{nullEmpty}, true},
+		{"empty func non-empty", a, &T{A: "foo", B: sql.NullString{String: "y", Valid: true}, Skip: 1, M: map[string][]int{"k": {1, 2}}}, Options{nullEmpty}, false},
+		{"different types", 1, int64(1), nil, false},
+		{"nil", nil, nil, nil, true},
+		{"nil and non-nil", nil, a, nil, false},
+		{"pointers seen in other pairs", e1, e2, nil, false},
+	}
+
+	for _, tt := range tests {
+		if got := Equal(tt.a, tt.b, tt.opts...); got != tt.want {
+			t.Errorf("%s: Equal = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
+func TestSortedMapKeys(t *testing.T) {
+	t.Parallel()
+
+	// Fields are visited in declaration order, not sorted by path.
+	type T struct {
+		B string
+		M map[string]int
+		N map[int]int
+		A string
+	}
+
+	newDst := func() *T {
+		return &T{
+			B: "foo",
+			M: map[string]int{"a": 1, "b": 2, "c": 3, "d": 4, "e": 5},
+			N: map[int]int{9: 9, 10: 10},
+			A: "foo",
+		}
+	}
+	src := T{
+		B: "bar",
+		M: map[string]int{"a": 10, "b": 20, "c": 30, "d": 40, "e": 50},
+		N: map[int]int{9: 90, 10: 100},
+		A: "bar",
+	}
+	want := []Change{
+		{".B", "foo", "bar"},
+		{".M[a]", 1, 10},
+		{".M[b]", 2, 20},
+		{".M[c]", 3, 30},
+		{".M[d]", 4, 40},
+		{".M[e]", 5, 50},
+		{".N[9]", 9, 90},
+		{".N[10]", 10, 100},
+		{".A", "foo", "bar"},
+	}
+	var wantTrace []string
+	for _, c := range want {
+		wantTrace = append(wantTrace, c.Path)
+	}
+
+	for i := 0; i < 20; i++ {
+		var changes []Change
+		if err := DeepMerge(newDst(), src, WithOverwrite(), WithDryRun(&changes), WithSortedMapKeys()); err != nil {
+			t.Fatal(err)
+		}
+		if !cmp.Equal(want, changes) {
+			t.Fatalf("run %d: dry run changes: %s", i, cmp.Diff(want, changes))
+		}
+
+		changes, err := ApplyAndDiff(newDst(), src, WithOverwrite(), WithSortedMapKeys())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !cmp.Equal(want, changes) {
+			t.Fatalf("run %d: ApplyAndDiff changes: %s", i, cmp.Diff(want, changes))
+		}
+
+		changes, err = Diff(newDst(), &src, WithSortedMapKeys())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !cmp.Equal(want, changes) {
+			t.Fatalf("run %d: Diff changes: %s", i, cmp.Diff(want, changes))
+		}
+
+		var trace []string
+		tracer := WithTracer(func(e TraceEvent) {
+			if TraceOverwrite == e.Action {
+				trace = append(trace, e.Path)
+			}
+		})
+		if err := DeepMerge(newDst(), src, WithOverwrite(), tracer, WithSortedMapKeys()); err != nil {
+			t.Fatal(err)
+		}
+		if !cmp.Equal(wantTrace, trace) {
+			t.Fatalf("run %d: traced overwrites: %s", i, cmp.Diff(wantTrace, trace))
+		}
+	}
+}
+
+func TestOmitEmptyTag(t *testing.T) {
+	t.Parallel()
+
+	type T struct {
+		A string `merge:"omitempty"`
+		B string
+		C *int `merge:"omitempty"`
+	}
+
+	tests := []test{
+		{
+			name:      "overwrite",
+			dst:       &T{A: "dst", B: "dst", C: New(1)},
+			src:       T{},
+			mergeOpts: Options{WithOverwriteWithEmptyValue()},
+			want:      &T{A: "dst", C: New(1)},
+		},
+		{
+			name:      "non-empty",
+			dst:       &T{A: "dst", B: "dst", C: New(1)},
+			src:       T{A: "src", B: "src", C: New(2)},
+			mergeOpts: Options{WithOverwrite()},
+			want:      &T{A: "src", B: "src", C: New(2)},
+		},
+	}
+
+	t.Run("Merge", func(t *testing.T) { testDeepMerge(t, tests...) })
+
+	t.Run("Map", func(t *testing.T) {
+		testDeepMap(t, append(tests, test{
+			name:      "from map",
+			dst:       &T{A: "dst", B: "dst"},
+			src:       map[string]any{"A": "", "B": ""},
+			mergeOpts: Options{WithOverwriteWithEmptyValue()},
+			want:      &T{A: "dst"},
+		})...)
+	})
+}
+
+func TestMergeWithNamedTransformer(t *testing.T) {
+	t.Parallel()
+
+	type Reading struct {
+		Value float64 `merge:"transform=rounded"`
+		Peak  float64 `merge:"transform=max"`
+		Raw   float64
+	}
+
+	opts := Options{
+		WithNamedTransformer("rounded", func(dst *float64, src float64) error {
+			*dst = math.Round(src)
+			return nil
+		}),
+		WithNamedTransformer("max", func(dst *float64, src float64) error {
+			*dst = math.Max(*dst, src)
+			return nil
+		}),
+	}
+
+	tests := []test{
+		{
+			name:      "named transformers",
+			dst:       &Reading{Value: 1, Peak: 9.5, Raw: 1},
+			src:       Reading{Value: 2.6, Peak: 3.5, Raw: 2.6},
+			mergeOpts: append(Options{WithOverwrite()}, opts...),
+			want:      &Reading{Value: 3, Peak: 9.5, Raw: 2.6},
+		},
+		{
+			name:    "unknown transformer",
+			dst:     &Reading{},
+			src:     Reading{Value: 1},
+			wantErr: true,
+		},
+		{
+			name: "mismatched transformer type",
+			dst:  &Reading{},
+			src:  Reading{Value: 1},
+			mergeOpts: Options{
+				WithNamedTransformer("rounded", func(dst *int, src int) error { return nil }),
+				WithNamedTransformer("max", func(dst *float64, src float64) error { return nil }),
+			},
+			wantErr: true,
+		},
+	}
+
+	t.Run("Merge", func(t *testing.T) { testDeepMerge(t, tests...) })
+
+	t.Run("Map", func(t *testing.T) { testDeepMap(t, tests...) })
+
+	t.Run("Duplicate", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected panic")
+			}
+		}()
+		var dst Reading
+		_ = DeepMerge(&dst, Reading{}, opts[1], opts[1])
+	})
+}
+
+func TestMergeWithTransformerFor(t *testing.T) {
+	t.Parallel()
+
+	type Event struct {
+		At      time.Time
+		Updated *time.Time
+		Deleted sql.NullTime
+	}
+
+	now := time.Now()
+	earlier := now.Add(-time.Hour)
+
+	// Keeps the later of two times, regardless of WithOverwrite.
+	later := WithTransformerFor([]reflect.Type{
+		reflect.TypeOf(time.Time{}),
+		reflect.TypeOf((*time.Time)(nil)),
+		reflect.TypeOf(sql.NullTime{}),
+	}, func(dst, src reflect.Value) error {
+		var d, s time.Time
+		switch v := dst.Elem().Interface().(type) {
+		case time.Time:
+			d, s = v, src.Interface().(time.Time)
+		case *time.Time:
+			if v != nil {
+				d = *v
+			}
+			if p := src.Interface().(*time.Time); p != nil {
+				s = *p
+			}
+		case sql.NullTime:
+			d, s = v.Time, src.Interface().(sql.NullTime).Time
+		}
+		if s.After(d) {
+			dst.Elem().Set(src)
+		}
+		return nil
+	})
+
+	tests := []test{
+		{
+			name:      "later",
+			dst:       &Event{At: earlier, Updated: &now, Deleted: sql.NullTime{Time: earlier, Valid: true}},
+			src:       Event{At: now, Updated: &earlier, Deleted: sql.NullTime{Time: now, Valid: true}},
+			mergeOpts: Options{later},
+			want:      &Event{At: now, Updated: &now, Deleted: sql.NullTime{Time: now, Valid: true}},
+		},
+		{
+			name: "error",
+			dst:  &Event{},
+			src:  Event{At: now},
+			mergeOpts: Options{WithTransformerFor([]reflect.Type{reflect.TypeOf(time.Time{})},
+				func(dst, src reflect.Value) error { return errors.New("transformer error") })},
+			wantErr: true,
+		},
+	}
+
+	t.Run("Merge", func(t *testing.T) { testDeepMerge(t, tests...) })
+
+	t.Run("Map", func(t *testing.T) { testDeepMap(t, tests...) })
+
+	t.Run("Duplicate", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected panic")
+			}
+		}()
+		var dst Event
+		_ = DeepMerge(&dst, Event{}, later, WithTimeTransformer(true))
+	})
+}
+
+func TestMergeWithMapConflict(t *testing.T) {
+	t.Parallel()
+
+	max := WithMapConflict(func(key, dstVal, srcVal reflect.Value) (reflect.Value, error) {
+		if srcVal.Int() > dstVal.Int() {
+			return srcVal, nil
+		}
+		return reflect.Value{}, nil
+	})
+	concat := WithMapConflict(func(key, dstVal, srcVal reflect.Value) (reflect.Value, error) {
+		return reflect.AppendSlice(dstVal, srcVal), nil
+	})
+
+	tests := []test{
+		{
+			name:      "max",
+			dst:       map[string]int{"a": 1, "b": 5},
+			src:       map[string]int{"a": 3, "b": 2, "c": 4},
+			mergeOpts: Options{max},
+			want:      map[string]int{"a": 3, "b": 5, "c": 4},
+		},
+		{
+			name:      "concat",
+			dst:       map[string][]int{"a": {1}, "b": {2}},
+			src:       map[string][]int{"a": {3}, "c": {4}},
+			mergeOpts: Options{concat},
+			want:      map[string][]int{"a": {1, 3}, "b": {2}, "c": {4}},
+		},
+		{
+			name: "keep dst",
+			dst:  map[string]int{"a": 1},
+			src:  map[string]int{"a": 2},
+			mergeOpts: Options{WithOverwrite(), WithMapConflict(func(key, dstVal, srcVal reflect.Value) (reflect.Value, error) {
+				return reflect.Value{}, nil
+			})},
+			want: map[string]int{"a": 1},
+		},
+		{
+			name: "error",
+			dst:  map[string]int{"a": 1},
+			src:  map[string]int{"a": 2},
+			mergeOpts: Options{WithMapConflict(func(key, dstVal, srcVal reflect.Value) (reflect.Value, error) {
+				return reflect.Value{}, errors.New("conflict on " + key.String())
+			})},
+			wantErr: true,
+		},
+		{
+			name: "wrong type",
+			dst:  map[string]int{"a": 1},
+			src:  map[string]int{"a": 2},
+			mergeOpts: Options{WithMapConflict(func(key, dstVal, srcVal reflect.Value) (reflect.Value, error) {
+				return reflect.ValueOf("foo"), nil
+			})},
+			wantErr: true,
+		},
+	}
+
+	t.Run("Merge", func(t *testing.T) { testDeepMerge(t, tests...) })
+
+	t.Run("Map", func(t *testing.T) { testDeepMap(t, tests...) })
+}
+
+func TestMergeWithKeyTransformer(t *testing.T) {
+	t.Parallel()
+
+	lower := WithKeyTransformer(func(k reflect.Value) reflect.Value {
+		return reflect.ValueOf(strings.ToLower(k.String()))
+	})
+	abs := WithKeyTransformer(func(k reflect.Value) reflect.Value {
+		if k.Int() < 0 {
+			return reflect.ValueOf(-k.Int())
+		}
+		return k
+	})
+
+	tests := func() []test {
+		return []test{
+			{
+				name:      "lower",
+				dst:       map[string]any{"Port": 0, "host": "localhost"},
+				src:       map[string]any{"port": 8080, "Debug": true},
+				mergeOpts: Options{lower},
+				want:      map[string]any{"port": 8080, "host": "localhost", "debug": true},
+			},
+			{
+				name:      "keep dst",
+				dst:       map[string]any{"Port": 80},
+				src:       map[string]any{"PORT": 8080},
+				mergeOpts: Options{lower},
+				want:      map[string]any{"port": 80},
+			},
+			{
+				name:      "nested",
+				dst:       map[string]any{"Server": map[string]any{"Port": 80}},
+				src:       map[string]any{"server": map[string]any{"TLS": true}},
+				mergeOpts: Options{lower},
+				want:      map[string]any{"server": map[string]any{"port": 80, "tls": true}},
+			},
+			{
+				name:      "prune",
+				dst:       map[string]int{"A": 1, "b": 2},
+				src:       map[string]int{"a": 3},
+				mergeOpts: Options{lower, WithOverwrite(), WithPruneMissingKeys()},
+				want:      map[string]int{"a": 3},
+			},
+			{
+				name:      "int keys",
+				dst:       map[int64]string{-1: "a"},
+				src:       map[int64]string{1: "b", -2: "c"},
+				mergeOpts: Options{abs, WithOverwrite()},
+				want:      map[int64]string{1: "b", 2: "c"},
+			},
+			{
+				name: "wrong type",
+				dst:  map[string]int{"a": 1},
+				src:  map[string]int{"a": 2},
+				mergeOpts: Options{WithKeyTransformer(func(k reflect.Value) reflect.Value {
+					return reflect.ValueOf(1)
+				})},
+				wantErr: true,
+			},
+		}
+	}
+
+	t.Run("Merge", func(t *testing.T) { testDeepMerge(t, tests()...) })
+
+	t.Run("Map", func(t *testing.T) { testDeepMap(t, tests()...) })
+}
+
+func TestMergeMap(t *testing.T) {
+	t.Parallel()
+
+	type T struct{ A, B int }
+
+	newDst := func() map[string]any {
+		shared := map[string]any{"x": 1}
+		return map[string]any{
+			"name":    "foo",
+			"empty":   "",
+			"count":   1,
+			"ratio":   0.5,
+			"enabled": false,
+			"nested": map[string]any{
+				"a": 1,
+				"b": map[string]any{"c": "d"},
+				"l": []any{1, map[string]any{"e": "f"}},
+			},
+			"list":   []any{"a", "b", "c"},
+			"struct": T{A: 1},
+			"nil":    nil,
+			"s1":     shared,
+			"s2":     shared,
+		}
+	}
+	newSrc := func() map[string]any {
+		self := map[string]any{"v": 1}
+		self["self"] = self
+		return map[string]any{
+			"name":    "bar",
+			"empty":   "baz",
+			"count":   0,
+			"ratio":   1.5,
+			"enabled": true,
+			"nested": map[string]any{
+				"a": 2,
+				"b": map[string]any{"g": "h"},
+				"l": []any{nil, map[string]any{"e": "i", "j": "k"}, 3},
+			},
+			"list":   []any{"x"},
+			"struct": T{A: 2, B: 3},
+			"mixed":  "one",
+			"nil":    map[string]any{"n": 1},
+			"new":    map[string]any{"m": []any{map[string]any{"o": 1}}},
+			"zero":   0,
+			"self":   self,
+		}
+	}
+
+	for _, opts := range []Options{
+		nil,
+		{WithOverwrite()},
+		{WithOverwriteWithEmptyValue()},
+		{WithOverwrite(), WithTypeCheck()},
+		{WithAppendSlice()},
+	} {
+		want, got := newDst(), newDst()
+		if err := DeepMerge(want, newSrc(), opts...); err != nil {
+			t.Fatal(err)
+		}
+		if err := MergeMap(got, newSrc(), opts...); err != nil {
+			t.Fatal(err)
+		}
+
+		// The cycle prevents comparing src values.
+		delete(want, "self")
+		delete(got, "self")
+		if !cmp.Equal(want, got) {
+			t.Errorf("%d options: %s", len(opts), cmp.Diff(want, got))
+		}
+	}
+
+	for _, opts := range []Options{nil, {WithOverwrite(), WithTypeCheck()}} {
+		dst, src := map[string]any{"a": 1}, map[string]any{"a": "one"}
+		if err := MergeMap(dst, src, opts...); err == nil {
+			t.Errorf("%d options: merging different types: want error got nil", len(opts))
+		}
+	}
+}
+
+func BenchmarkMergeMap(b *testing.B) {
+	newTree := func(depth, width int, leaf any) map[string]any {
+		var build func(int) map[string]any
+		build = func(d int) map[string]any {
+			m := make(map[string]any, width)
+			for i := 0; i < width; i++ {
+				k := string(rune('a' + i))
+				if d == 0 {
+					m[k] = leaf
+				} else {
+					m[k] = build(d - 1)
+				}
+			}
+			m["list"] = []any{leaf, leaf}
+			return m
+		}
+		return build(depth)
+	}
+	src := newTree(3, 5, "value")
+
+	b.Run("MergeMap", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := MergeMap(newTree(3, 5, ""), src, WithOverwrite()); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("DeepMerge", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := DeepMerge(newTree(3, 5, ""), src, WithOverwrite()); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestMergeValue(t *testing.T) {
+	t.Parallel()
+
+	type Inner struct{ N int }
+	type Config struct {
+		Name  string
+		Inner *Inner
+		Tags  map[string]string
+	}
+
+	t.Run("Merge", func(t *testing.T) {
+		dst := reflect.New(reflect.TypeOf(Config{})).Elem()
+		dst.FieldByName("Name").SetString("foo")
+		src := reflect.ValueOf(Config{Name: "bar", Inner: &Inner{1}, Tags: map[string]string{"a": "b"}})
+		if err := MergeValue(dst, src); err != nil {
+			t.Fatal(err)
+		}
+		want := Config{Name: "foo", Inner: &Inner{1}, Tags: map[string]string{"a": "b"}}
+		if got := dst.Interface(); !cmp.Equal(want, got) {
+			t.Error(cmp.Diff(want, got))
+		}
+	})
+
+	t.Run("Map", func(t *testing.T) {
+		dst := reflect.New(reflect.TypeOf(Config{})).Elem()
+		src := reflect.ValueOf(map[string]any{"name": "bar", "inner": map[string]any{"N": 2}})
+		if err := MapValue(dst, src, WithOverwrite()); err != nil {
+			t.Fatal(err)
+		}
+		want := Config{Name: "bar", Inner: &Inner{2}}
+		if got := dst.Interface(); !cmp.Equal(want, got) {
+			t.Error(cmp.Diff(want, got))
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		for _, f := range []func(dst, src reflect.Value, opts ...Option) error{MergeValue, MapValue} {
+			if err := f(reflect.ValueOf(Config{}), reflect.ValueOf(Config{})); err == nil {
+				t.Error("unaddressable dst: want error got nil")
+			}
+			if err := f(reflect.Value{}, reflect.ValueOf(Config{})); err == nil {
+				t.Error("invalid dst: want error got nil")
+			}
+			dst := reflect.New(reflect.TypeOf(Config{})).Elem()
+			if err := f(dst, reflect.ValueOf(Config{}), WithTypeCheck()); err == nil {
+				t.Error("invalid options: want error got nil")
+			}
+		}
+	})
+}
+
+func TestMergeWithDefaultsOnly(t *testing.T) {
+	t.Parallel()
+
+	type T struct {
+		A string
+		B int
+		C []int
+		M map[string]int
+	}
+
+	// Options that change non-empty dst values are rejected.
+	conflicts := map[string]Options{
+		"overwrite after":                   {WithDefaultsOnly(), WithOverwrite()},
+		"overwrite with empty value before": {WithOverwriteWithEmptyValue(), WithDefaultsOnly()},
+		"nested options": {WithDefaultsOnly(), Options{WithOverwrite(), WithTypeCheck(),
+			WithOverwriteIf(func(dst, src reflect.Value) bool { return true })}},
+		"numeric add":        {WithDefaultsOnly(), WithNumericAdd()},
+		"string concat":      {WithStringConcat("+"), WithDefaultsOnly()},
+		"prune missing keys": {WithDefaultsOnly(), WithPruneMissingKeys()},
+		"slice truncate":     {WithDefaultsOnly(), WithSliceTruncate()},
+		"slice replace":      {WithSliceStrategy(SliceReplace), WithDefaultsOnly()},
+	}
+
+	tests := []test{
+		{
+			name:      "defaults",
+			dst:       &T{A: "foo", C: []int{1}, M: map[string]int{"a": 1}},
+			src:       T{A: "bar", B: 2, C: []int{3, 4}, M: map[string]int{"a": 2, "b": 2}},
+			mergeOpts: Options{WithDefaultsOnly()},
+			want:      &T{A: "foo", B: 2, C: []int{1, 4}, M: map[string]int{"a": 1, "b": 2}},
+		},
+		{
+			name: "scoped overwrite",
+			dst:  &T{A: "foo", B: 1},
+			src:  T{A: "bar", B: 2},
+			mergeOpts: Options{WithDefaultsOnly(),
+				WithOptionsAt(".A", WithOverwrite()), WithOptionsAt("[A]", WithOverwrite())},
+			wantErr: true,
+		},
+	}
+	for name, opts := range conflicts {
+		tests = append(tests, test{
+			name:      name,
+			dst:       &T{A: "foo", B: 1},
+			src:       T{A: "bar", B: 2},
+			mergeOpts: opts,
+			wantErr:   true,
+		})
+	}
+
+	t.Run("Merge", func(t *testing.T) { testDeepMerge(t, tests...) })
+
+	t.Run("Map", func(t *testing.T) { testDeepMap(t, tests...) })
+}
+
+func TestMergeWithSrcWinsDstWins(t *testing.T) {
+	t.Parallel()
+
+	type T struct {
+		A string
+		B int
+	}
+
+	dst, src := &T{A: "dst"}, T{A: "src", B: 2}
+	tests := []test{
+		{name: "default", dst: dst, src: src, want: &T{A: "dst", B: 2}},
+		{name: "src wins", dst: dst, src: src, mergeOpts: Options{WithSrcWins()}, want: &T{A: "src", B: 2}},
+		{name: "dst wins", dst: dst, src: src, mergeOpts: Options{WithDstWins()}, want: &T{A: "dst", B: 2}},
+		{
+			name:      "dst wins before src wins",
+			dst:       dst,
+			src:       src,
+			mergeOpts: Options{WithDstWins(), WithSrcWins()},
+			wantErr:   true,
+		},
+		{
+			name:      "dst wins after src wins",
+			dst:       dst,
+			src:       src,
+			mergeOpts: Options{WithSrcWins(), WithDstWins()},
+			wantErr:   true,
+		},
+		{
+			name:      "dst wins after overwrite with empty value",
+			dst:       &T{A: "dst", B: 1},
+			src:       T{},
+			mergeOpts: Options{WithSrcWins(), WithOverwriteWithEmptyValue(), WithDstWins()},
+			wantErr:   true,
+		},
+	}
+
+	t.Run("Merge", func(t *testing.T) { testDeepMerge(t, tests...) })
+
+	t.Run("Map", func(t *testing.T) { testDeepMap(t, tests...) })
+}
+
+func TestMergeWithTracer(t *testing.T) {
+	t.Parallel()
+
+	type T struct {
+		A string
+		B int
+		C *int64
+		D []int
+		e int
+	}
+
+	var events []TraceEvent
+	dst := &T{A: "foo", C: New[int64](0)}
+	src := T{A: "bar", B: 2, C: New[int64](3), D: []int{1}, e: 1}
+	if err := DeepMerge(dst, src, WithTracer(func(e TraceEvent) { events = append(events, e) })); err != nil {
+		t.Fatal(err)
+	}
+
+	intType, int64Type := reflect.TypeOf(0), reflect.TypeOf(int64(0))
+	for _, want := range []TraceEvent{
+		{"", TraceRecurse, reflect.TypeOf(T{}), reflect.TypeOf(T{})},
+		{".A", TraceSkip, reflect.TypeOf(""), reflect.TypeOf("")},
+		{".B", TraceOverwrite, intType, intType},
+		{".C", TraceRecurse, reflect.TypeOf(dst.C), reflect.TypeOf(src.C)},
+		{"(*.C)", TraceOverwrite, int64Type, int64Type},
+		{".D", TraceRecurse, reflect.TypeOf(src.D), reflect.TypeOf(src.D)},
+		{".D[0]", TraceOverwrite, intType, intType},
+	} {
+		found := false
+		for _, e := range events {
+			found = found || e == want
+		}
+		if !found {
+			t.Errorf("missing %+v in %+v", want, events)
+		}
+	}
+	for _, e := range events {
+		if e.Path == ".e" {
+			t.Errorf("unexpected %+v", e)
+		}
+	}
+}
+
+func TestMergeWithExplicitEmpty(t *testing.T) {
+	t.Parallel()
+
+	type T struct {
+		Name string
+		Age  int
+		Tags []string
+	}
+
+	src := map[string]any{"name": "", "age": 0, "tags": []string{}, "nil": nil}
+	tests := []test{
+		{
+			name:      "map",
+			dst:       map[string]any{"name": "foo", "age": 42, "tags": []string{"a"}, "nil": 1, "absent": "bar"},
+			src:       src,
+			mergeOpts: Options{WithOverwrite(), WithExplicitEmpty()},
+			want:      map[string]any{"name": "", "age": 0, "tags": []string{"a"}, "nil": 1, "absent": "bar"},
+		},
+		{
+			name:      "without overwrite",
+			dst:       map[string]any{"name": "foo", "age": 42},
+			src:       map[string]any{"name": "", "age": 0, "new": ""},
+			mergeOpts: Options{WithExplicitEmpty()},
+			want:      map[string]any{"name": "foo", "age": 42, "new": ""},
+		},
+	}
+
+	t.Run("Merge", func(t *testing.T) { testDeepMerge(t, tests...) })
+
+	tests = append(tests,
+		test{
+			name:      "struct",
+			dst:       &T{Name: "foo", Age: 42, Tags: []string{"a"}},
+			src:       src,
+			mergeOpts: Options{WithOverwrite(), WithExplicitEmpty()},
+			want:      &T{Tags: []string{"a"}},
+		},
+		test{
+			name:      "struct absent",
+			dst:       &T{Name: "foo", Age: 42},
+			src:       map[string]any{},
+			mergeOpts: Options{WithOverwrite(), WithExplicitEmpty()},
+			want:      &T{Name: "foo", Age: 42},
+		},
+	)
+	t.Run("Map", func(t *testing.T) { testDeepMap(t, tests...) })
+}
+
+func TestMergeWithElementTransformer(t *testing.T) {
+	t.Parallel()
+
+	type T struct {
+		Name string
+		Tags []string
+	}
+
+	trim := WithElementTransformer(reflect.TypeOf([]string(nil)), func(dst *string, src string) error {
+		*dst = strings.TrimSpace(src)
+		return nil
+	})
+	tests := []test{
+		{
+			name:      "elements only",
+			dst:       &T{Tags: []string{"a"}},
+			src:       T{Name: " foo ", Tags: []string{" b ", " c"}},
+			mergeOpts: Options{trim},
+			want:      &T{Name: " foo ", Tags: []string{"b", "c"}},
+		},
+		{
+			name:      "other slice types",
+			dst:       &[]any{},
+			src:       []any{" a "},
+			mergeOpts: Options{trim},
+			want:      &[]any{" a "},
+		},
+		{
+			name:      "appended elements",
+			dst:       &T{Tags: []string{"a"}},
+			src:       T{Tags: []string{" b "}},
+			mergeOpts: Options{trim, WithAppendSlice()},
+			want:      &T{Tags: []string{"a", " b "}},
+		},
+		{
+			name: "error",
+			dst:  &[]int{1},
+			src:  []int{2},
+			mergeOpts: Options{WithElementTransformer(reflect.TypeOf([]int(nil)), func(dst *int, src int) error {
+				return errors.New("error")
+			})},
+			wantErr: true,
+		},
+	}
+
+	t.Run("Merge", func(t *testing.T) { testDeepMerge(t, tests...) })
+
+	t.Run("Map", func(t *testing.T) { testDeepMap(t, tests...) })
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, opt := range []Option{
+			WithElementTransformer(reflect.TypeOf(""), func(dst *string, src string) error { return nil }),
+			WithElementTransformer(reflect.TypeOf([]int(nil)), func(dst *string, src string) error { return nil }),
+		} {
+			func() {
+				defer func() {
+					if recover() == nil {
+						t.Error("expected panic")
+					}
+				}()
+				_ = DeepMerge(&[]int{}, []int{}, opt)
+			}()
+		}
+	})
+}
+
+func TestMergeWithAppendMapSlices(t *testing.T) {
+	t.Parallel()
+
+	type T struct {
+		S []int
+		M map[string][]int
+	}
+
+	// Merging changes the maps of dst in place, so each run needs its own.
+	tests := func() []test {
+		return []test{
+			{
+				name:      "map",
+				dst:       map[string][]int{"a": {1}, "b": {2}},
+				src:       map[string][]int{"a": {3}, "c": {4}},
+				mergeOpts: Options{WithAppendMapSlices()},
+				want:      map[string][]int{"a": {1, 3}, "b": {2}, "c": {4}},
+			},
+			{
+				name:      "interface values",
+				dst:       map[string]any{"a": []string{"x"}, "b": 1},
+				src:       map[string]any{"a": []string{"y"}, "b": 2},
+				mergeOpts: Options{WithOverwrite(), WithAppendMapSlices()},
+				want:      map[string]any{"a": []string{"x", "y"}, "b": 2},
+			},
+			{
+				name:      "struct",
+				dst:       &T{S: []int{1}, M: map[string][]int{"a": {1}}},
+				src:       T{S: []int{2}, M: map[string][]int{"a": {2}}},
+				mergeOpts: Options{WithOverwrite(), WithAppendMapSlices()},
+				want:      &T{S: []int{2}, M: map[string][]int{"a": {1, 2}}},
+			},
+			{
+				name:      "top-level slice",
+				dst:       &[]int{1},
+				src:       []int{2},
+				mergeOpts: Options{WithOverwrite(), WithAppendMapSlices()},
+				want:      &[]int{2},
+			},
+		}
+	}
+
+	t.Run("Merge", func(t *testing.T) { testDeepMerge(t, tests()...) })
+
+	t.Run("Map", func(t *testing.T) { testDeepMap(t, tests()...) })
+}
+
+func TestMergeWithPartialTransformer(t *testing.T) {
+	t.Parallel()
+
+	type T struct {
+		Name  string
+		Tags  []string
+		Count int
+	}
+
+	// Normalizes dst names before merging, and sums counts.
+	normalize := WithPartialTransformer(func(dst, src reflect.Value) (bool, error) {
+		switch dst.Kind() {
+		case reflect.String:
+			dst.SetString(strings.ToLower(dst.String()))
+		case reflect.Int:
+			dst.SetInt(dst.Int() + src.Int())
+			return true, nil
+		}
+		return false, nil
+	})
+
+	tests := []test{
+		{
+			name:      "continue and handle",
+			dst:       &T{Name: "FOO", Tags: []string{"A"}, Count: 1},
+			src:       T{Name: "bar", Tags: []string{"b", "c"}, Count: 2},
+			mergeOpts: Options{normalize},
+			want:      &T{Name: "foo", Tags: []string{"a", "c"}, Count: 3},
+		},
+		{
+			name:      "overwrite",
+			dst:       &T{Name: "FOO"},
+			src:       T{Name: "bar"},
+			mergeOpts: Options{normalize, WithOverwrite()},
+			want:      &T{Name: "bar"},
+		},
+		{
+			name: "error",
+			dst:  &T{},
+			src:  T{},
+			mergeOpts: Options{WithPartialTransformer(func(dst, src reflect.Value) (bool, error) {
+				return false, errors.New("error")
+			})},
+			wantErr: true,
+		},
+	}
+
+	t.Run("Merge", func(t *testing.T) { testDeepMerge(t, tests...) })
+
+	t.Run("Map", func(t *testing.T) { testDeepMap(t, tests...) })
+}
+
+func TestMergeWithFieldAlias(t *testing.T) {
+	t.Parallel()
+
+	type Inner struct{ N int }
+	type A struct {
+		Name  string
+		Count int
+		Inner Inner
+		Extra string
+	}
+	type B struct {
+		Title string
+		Count int
+		Inner Inner
+		Name  string
+	}
+
+	alias := WithFieldAlias(map[string]string{"Name": "Title"})
+	tests := []test{
+		{
+			name:      "alias",
+			dst:       &B{Count: 1},
+			src:       A{Name: "foo", Count: 2, Inner: Inner{3}, Extra: "bar"},
+			mergeOpts: Options{alias},
+			want:      &B{Title: "foo", Count: 1, Inner: Inner{3}},
+		},
+		{
+			name:      "overwrite",
+			dst:       &B{Title: "foo", Count: 1},
+			src:       A{Name: "bar", Count: 2},
+			mergeOpts: Options{alias, WithOverwrite()},
+			want:      &B{Title: "bar", Count: 2},
+		},
+		{
+			name:      "same type",
+			dst:       &A{Name: "foo"},
+			src:       A{Name: "bar", Count: 2},
+			mergeOpts: Options{alias},
+			want:      &A{Name: "foo", Count: 2},
+		},
+	}
+
+	t.Run("Merge", func(t *testing.T) { testDeepMerge(t, tests...) })
+
+	t.Run("Map", func(t *testing.T) { testDeepMap(t, tests...) })
+
+	t.Run("Merge paths", func(t *testing.T) {
+		var paths []string
+		tracer := WithTracer(func(e TraceEvent) {
+			if TraceOverwrite == e.Action {
+				paths = append(paths, e.Path)
+			}
+		})
+		var dst B
+		if err := DeepMerge(&dst, A{Name: "foo", Inner: Inner{3}}, alias, tracer); err != nil {
+			t.Fatal(err)
+		}
+		if want := []string{".Title", ".Inner.N"}; !cmp.Equal(want, paths) {
+			t.Error(cmp.Diff(want, paths))
+		}
+	})
+
+	t.Run("Merge rules", func(t *testing.T) {
+		// DeepMerge does not map integers into strings like DeepMap.
+		type C struct{ Title int }
+		if err := DeepMerge(&B{}, C{Title: 65}, alias); err == nil {
+			t.Error("want error got nil")
+		}
+	})
+}
+
+func TestMergeWithNaNPolicy(t *testing.T) {
+	t.Parallel()
+
+	type T struct {
+		X float64
+		Y float32
+	}
+
+	nan := math.NaN()
+	tests := []test{
+		{
+			name:      "skip",
+			dst:       &T{X: 1},
+			src:       T{X: nan, Y: float32(nan)},
+			mergeOpts: Options{WithOverwrite(), WithNaNPolicy(NaNSkip)},
+			want:      &T{X: 1},
+		},
+		{
+			name:      "skip in map",
+			dst:       map[string]any{"x": 1.0},
+			src:       map[string]any{"x": nan},
+			mergeOpts: Options{WithOverwrite(), WithNaNPolicy(NaNSkip)},
+			want:      map[string]any{"x": 1.0},
+		},
+		{
+			name:      "error",
+			dst:       &T{X: 1},
+			src:       T{X: nan},
+			mergeOpts: Options{WithNaNPolicy(NaNError)},
+			wantErr:   true,
+		},
+		{
+			name:      "allow",
+			dst:       &T{X: 1},
+			src:       T{X: nan, Y: 2},
+			mergeOpts: Options{WithOverwrite(), WithNaNPolicy(NaNAllow)},
+			check: func(t testing.TB, dst any) {
+				if d := dst.(*T); !math.IsNaN(d.X) || d.Y != 2 {
+					t.Errorf("got %+v, want {X:NaN Y:2}", *d)
+				}
+			},
+		},
+		{
+			name:      "not NaN",
+			dst:       &T{X: 1},
+			src:       T{X: 2},
+			mergeOpts: Options{WithOverwrite(), WithNaNPolicy(NaNError)},
+			want:      &T{X: 2},
+		},
+	}
+
+	t.Run("Merge", func(t *testing.T) { testDeepMerge(t, tests...) })
+
+	t.Run("Map", func(t *testing.T) { testDeepMap(t, tests...) })
+}
+
+type Color int
+
+const (
+	Red Color = iota
+	Green
+	Blue
+	Black
+)
+
+func TestMergeWithEnumTransformer(t *testing.T) {
+	t.Parallel()
+
+	type T struct {
+		Fg, Bg Color
+	}
+
+	colors := WithEnumTransformer(func(c Color) bool { return Red <= c && c <= Black })
+	tests := []test{
+		{
+			name:      "valid",
+			dst:       &T{Fg: Green, Bg: Blue},
+			src:       T{Fg: Black},
+			mergeOpts: Options{colors, WithOverwrite()},
+			want:      &T{Fg: Black, Bg: Blue},
+		},
+		{
+			name:      "unset",
+			dst:       &T{Fg: Blue},
+			src:       T{Bg: Green},
+			mergeOpts: Options{WithEnumTransformer(func(c Color) bool { return Green <= c && c <= Black })},
+			want:      &T{Fg: Blue, Bg: Green},
+		},
+		{
+			name:      "no overwrite",
+			dst:       &T{Fg: Green},
+			src:       T{Fg: Black, Bg: Blue},
+			mergeOpts: Options{colors},
+			want:      &T{Fg: Green, Bg: Blue},
+		},
+		{
+			name:      "dst wins",
+			dst:       &T{Fg: Green},
+			src:       T{Fg: Black, Bg: Blue},
+			mergeOpts: Options{colors, WithDstWins()},
+			want:      &T{Fg: Green, Bg: Blue},
+		},
+		{
+			name:      "scoped overwrite",
+			dst:       &T{Fg: Green, Bg: Green},
+			src:       T{Fg: Black, Bg: Blue},
+			mergeOpts: Options{colors, WithOptionsAt(".Fg", WithOverwrite()), WithOptionsAt("[Fg]", WithOverwrite())},
+			want:      &T{Fg: Black, Bg: Green},
+		},
+		{
+			name:      "invalid",
+			dst:       &T{Fg: Green},
+			src:       T{Fg: 4},
+			mergeOpts: Options{colors},
+			wantErr:   true,
+		},
+		{
+			name:      "negative",
+			dst:       New(Blue),
+			src:       Color(-1),
+			mergeOpts: Options{colors},
+			wantErr:   true,
+		},
+	}
+
+	t.Run("Merge", func(t *testing.T) { testDeepMerge(t, tests...) })
+
+	t.Run("Map", func(t *testing.T) { testDeepMap(t, tests...) })
+
+	t.Run("Keep dst", func(t *testing.T) {
+		dst := T{Fg: Green}
+		err := DeepMerge(&dst, T{Fg: 4}, colors)
+		if err == nil || !strings.Contains(err.Error(), "invalid merge_test.Color value 4") {
+			t.Errorf("err = %v", err)
+		}
+		if dst.Fg != Green {
+			t.Errorf("Fg = %d, want %d", dst.Fg, Green)
+		}
+	})
+}
+
+func TestMergeWithFillNilPointers(t *testing.T) {
+	t.Parallel()
+
+	type Inner struct {
+		N int
+		P *int
+	}
+	type T struct {
+		A *Inner
+		B *Inner
+		C *string
+		S string
+	}
+
+	newSrc := func() T {
+		return T{A: &Inner{N: 1, P: New(2)}, B: &Inner{N: 3}, C: New("src"), S: "src"}
+	}
+	tests := []test{
+		{
+			name:      "fill",
+			dst:       &T{B: &Inner{}},
+			src:       newSrc(),
+			mergeOpts: Options{WithFillNilPointers()},
+			want:      &T{A: &Inner{N: 1, P: New(2)}, B: &Inner{}, C: New("src"), S: "src"},
+		},
+		{
+			name:      "overwrite",
+			dst:       &T{B: &Inner{N: 4}, C: New(""), S: "dst"},
+			src:       newSrc(),
+			mergeOpts: Options{WithFillNilPointers(), WithOverwrite()},
+			want:      &T{A: &Inner{N: 1, P: New(2)}, B: &Inner{N: 4}, C: New(""), S: "src"},
+		},
+		{
+			name:      "overwrite with empty value",
+			dst:       &T{B: &Inner{N: 4}},
+			src:       T{},
+			mergeOpts: Options{WithFillNilPointers(), WithOverwriteWithEmptyValue()},
+			want:      &T{B: &Inner{N: 4}},
+		},
+		{
+			name:      "without dereference",
+			dst:       &T{B: &Inner{}},
+			src:       newSrc(),
+			mergeOpts: Options{WithFillNilPointers(), WithoutDereference()},
+			want:      &T{A: &Inner{N: 1, P: New(2)}, B: &Inner{}, C: New("src"), S: "src"},
+		},
+	}
+
+	t.Run("Merge", func(t *testing.T) { testDeepMerge(t, tests...) })
+
+	t.Run("Map", func(t *testing.T) { testDeepMap(t, tests...) })
+
+	t.Run("No aliasing", func(t *testing.T) {
+		src := newSrc()
+		var dst T
+		if err := DeepMerge(&dst, src, WithFillNilPointers(), WithoutDereference()); err != nil {
+			t.Fatal(err)
+		}
+		if dst.A == src.A || dst.A.P == src.A.P || dst.C == src.C {
+			t.Error("dst pointers alias src pointers")
+		}
+	})
+}
+
+func TestMergeWithInitEmptyMaps(t *testing.T) {
+	t.Parallel()
+
+	type T struct {
+		M map[string]int
+		N map[string]int
+	}
+
+	tests := []test{
+		{
+			name: "default",
+			dst:  &T{},
+			src:  T{M: map[string]int{}},
+			want: &T{},
+		},
+		{
+			name:      "init",
+			dst:       &T{},
+			src:       T{M: map[string]int{}},
+			mergeOpts: Options{WithInitEmptyMaps()},
+			want:      &T{M: map[string]int{}},
+			check: func(t testing.TB, dst any) {
+				if d := dst.(*T); d.M == nil || d.N != nil {
+					t.Errorf("M = %#v, N = %#v, want non-nil M and nil N", d.M, d.N)
+				}
+			},
+		},
+		{
+			name:      "non-empty",
+			dst:       &T{},
+			src:       T{M: map[string]int{"a": 1}},
+			mergeOpts: Options{WithInitEmptyMaps()},
+			want:      &T{M: map[string]int{"a": 1}},
+		},
+	}
+
+	t.Run("Merge", func(t *testing.T) { testDeepMerge(t, tests...) })
+
+	t.Run("Map", func(t *testing.T) { testDeepMap(t, tests...) })
+}
+func TestMergeWithHooks(t *testing.T) {
+	t.Parallel()
+
+	type T struct {
+		A     int
+		Items []string
+		M     map[string]int
+	}
+
+	dst := func() *T { return &T{A: 1, Items: []string{"a"}, M: map[string]int{"x": 1}} }
+	src := T{A: 2, Items: []string{"b", "c"}, M: map[string]int{"x": 2, "y": 3}}
+
+	testHooks := func(t *testing.T, merge mergeFunc, fieldPath, elemPath, mapPath string) {
+		before := make(map[string]any)
+		after := make(map[string]any)
+		hooks := WithHooks(func(path string, dst, src reflect.Value) {
+			before[path] = dst.Interface()
+		}, func(path string, dst, src reflect.Value) {
+			if _, ok := before[path]; !ok {
+				t.Errorf("after without before for %q", path)
+			}
+			after[path] = dst.Interface()
+		})
+
+		if err := merge(dst(), src, hooks, WithOverwrite()); err != nil {
+			t.Fatal(err)
+		}
+
+		for path, want := range map[string][2]any{
+			fieldPath: {1, 2},
+			elemPath:  {"", "c"},
+			mapPath:   {1, 2},
+		} {
+			if got := [2]any{before[path], after[path]}; got != want {
+				t.Errorf("%q: before, after = %v, want %v", path, got, want)
+			}
+		}
+		if len(before) != len(after) {
+			t.Errorf("%d before hooks, %d after hooks", len(before), len(after))
+		}
+	}
+
+	t.Run("Merge", func(t *testing.T) { testHooks(t, DeepMerge, ".A", ".Items[1]", ".M[x]") })
+
+	t.Run("Map", func(t *testing.T) { testHooks(t, DeepMap, "[A]", "[Items][1]", "[M][x]") })
+}
+
+// countingAllocator counts the values it allocates with the reflect package.
+type countingAllocator struct {
+	news, slices, maps int
+}
+
+func (a *countingAllocator) New(typ reflect.Type) reflect.Value {
+	a.news++
+	return reflect.New(typ)
+}
+
+func (a *countingAllocator) MakeSlice(typ reflect.Type, len, cap int) reflect.Value {
+	a.slices++
+	return reflect.MakeSlice(typ, len, cap)
+}
+
+func (a *countingAllocator) MakeMapWithSize(typ reflect.Type, n int) reflect.Value {
+	a.maps++
+	return reflect.MakeMapWithSize(typ, n)
+}
+
+func TestMergeWithAllocator(t *testing.T) {
+	t.Parallel()
+
+	type T struct {
+		P *int
+		S []int
+		M map[string]int
+	}
+
+	testMergeFuncs(t, func(t *testing.T, merge mergeFunc) {
+		t.Parallel()
+
+		tests := []struct {
+			name      string
+			dst, src  T
+			opts      Options
+			want      T
+			allocated countingAllocator
+		}{
+			{
+				name:      "new values",
+				dst:       T{S: []int{1}},
+				src:       T{P: New(1), S: []int{1, 2}, M: map[string]int{"a": 1}},
+				want:      T{P: New(1), S: []int{1, 2}, M: map[string]int{"a": 1}},
+				allocated: countingAllocator{news: 2, slices: 1, maps: 1},
+			},
+			{
+				name:      "map values",
+				dst:       T{M: map[string]int{"a": 1}},
+				src:       T{M: map[string]int{"a": 2, "b": 2}},
+				opts:      Options{WithOverwrite()},
+				want:      T{M: map[string]int{"a": 2, "b": 2}},
+				allocated: countingAllocator{news: 2},
+			},
+			{
+				name:      "append",
+				dst:       T{S: []int{1}},
+				src:       T{S: []int{2}},
+				opts:      Options{WithAppendSlice()},
+				want:      T{S: []int{1, 2}},
+				allocated: countingAllocator{slices: 1},
+			},
+			{
+				name:      "append unique",
+				dst:       T{S: []int{1}},
+				src:       T{S: []int{1, 2}},
+				opts:      Options{WithSliceStrategy(SliceAppendUnique)},
+				want:      T{S: []int{1, 2}},
+				allocated: countingAllocator{slices: 1},
+			},
+			{
+				name:      "preserve cap",
+				dst:       T{S: []int{1}},
+				src:       T{S: []int{2, 3}},
+				opts:      Options{WithOverwrite(), WithPreserveCap()},
+				want:      T{S: []int{2, 3}},
+				allocated: countingAllocator{slices: 1},
+			},
+			{
+				name:      "prepend",
+				dst:       T{S: []int{1}},
+				src:       T{S: []int{2}},
+				opts:      Options{WithPrependSlice()},
+				want:      T{S: []int{2, 1}},
+				allocated: countingAllocator{slices: 1},
+			},
+		}
+
+		for _, tt := range tests {
+			var a countingAllocator
+			dst := tt.dst
+			if err := merge(&dst, tt.src, append(tt.opts, WithAllocator(&a))...); err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			if !cmp.Equal(tt.want, dst) {
+				t.Errorf("%s: got %+v, want %+v", tt.name, dst, tt.want)
+			}
+			if a != tt.allocated {
+				t.Errorf("%s: allocations = %+v, want %+v", tt.name, a, tt.allocated)
+			}
+		}
+	})
+}
+
+func TestMergeWithParallelism(t *testing.T) {
+	t.Parallel()
+
+	type Item struct {
+		N    int
+		Tags []string
+		P    *int
+	}
+
+	const n = 5000
+	newMaps := func() (dst, src map[string]Item) {
+		dst, src = make(map[string]Item), make(map[string]Item)
+		for i := 0; i < n; i++ {
+			k := strconv.Itoa(i)
+			if i%2 == 0 {
+				dst[k] = Item{N: i, Tags: []string{"dst"}}
+			}
+			if i%3 != 0 {
+				src[k] = Item{N: -i, Tags: []string{"src", "src"}, P: New(i)}
+			}
+		}
+		return dst, src
+	}
+
+	want, src := newMaps()
+	if err := DeepMerge(&want, src, WithOverwrite()); err != nil {
+		t.Fatal(err)
+	}
+
+	dst, src := newMaps()
+	if err := DeepMerge(&dst, src, WithOverwrite(), WithParallelism(4)); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, dst); diff != "" {
+		t.Errorf("parallel merge differs from sequential merge (-want +got):\n%s", diff)
+	}
+	for k, v := range dst {
+		if s, ok := src[k]; ok && s.P == v.P {
+			t.Fatalf("dst[%s].P aliases src", k)
+		}
+	}
+
+	dst, src = newMaps()
+	err := DeepMerge(&dst, src, WithParallelism(4), WithTransformer(func(dst *int, src int) error {
+		if src == n-1 {
+			return errors.New("transformer error")
+		}
+		*dst = src
+		return nil
+	}))
+	if err == nil {
+		t.Error("want error got nil")
+	}
+}
+
+func TestMergeWithNilSrcNoop(t *testing.T) {
+	t.Parallel()
+
+	testMergeFuncs(t, func(t *testing.T, merge mergeFunc) {
+		t.Parallel()
+
+		dst := T{A: 1}
+		if err := merge(&dst, nil); err == nil {
+			t.Error("nil src without option: want error got nil")
+		}
+		for _, src := range []any{nil, (*T)(nil)} {
+			if err := merge(&dst, src, WithNilSrcNoop()); err != nil {
+				t.Errorf("src %#v: %v", src, err)
+			}
+		}
+		if dst.A != 1 {
+			t.Errorf("dst.A = %d, want 1", dst.A)
+		}
+
+		for _, dst := range []any{nil, (*T)(nil)} {
+			if err := merge(dst, T{A: 2}, WithNilSrcNoop()); !errors.Is(err, ErrNilDst) {
+				t.Errorf("dst %#v: got error %v, want ErrNilDst", dst, err)
+			}
+		}
+
+		if err := merge(&dst, T{A: 2}, WithNilSrcNoop(), WithOverwrite()); err != nil || dst.A != 2 {
+			t.Errorf("got %d, %v, want 2, nil", dst.A, err)
+		}
+	})
+}
+
+func TestMergeWithRecover(t *testing.T) {
+	t.Parallel()
+
+	type T struct{ A, B int }
+
+	errBoom := errors.New("boom")
+	boom := func(path string, dst, src reflect.Value) {
+		if strings.Contains(path, "B") {
+			panic(errBoom)
+		}
+	}
+
+	testMergeFuncs(t, func(t *testing.T, merge mergeFunc) {
+		t.Parallel()
+
+		var dst T
+		err := merge(&dst, T{A: 1, B: 2}, WithHooks(boom, nil), WithRecover())
+		if !errors.Is(err, errBoom) {
+			t.Fatalf("got error %v, want errBoom", err)
+		}
+		if !strings.Contains(err.Error(), "B") {
+			t.Errorf("error %q does not have the path", err)
+		}
+
+		err = merge(&dst, T{}, WithHooks(func(string, reflect.Value, reflect.Value) { panic("boom") }, nil), WithRecover())
+		if err == nil || !strings.Contains(err.Error(), "panic: boom") {
+			t.Errorf("got error %v, want panic: boom", err)
+		}
+
+		// reflect panics setting fields of structs held in unexported fields.
+		type in struct{ X int }
+		type U struct{ in in }
+		if err := merge(&U{}, U{in{1}}, WithRecover()); err == nil || !strings.Contains(err.Error(), "X") {
+			t.Errorf("got error %v, want reflect panic at X", err)
+		}
+
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("without WithRecover: want panic")
+				}
+			}()
+			_ = merge(&dst, T{A: 1, B: 2}, WithHooks(boom, nil))
+		}()
+	})
+}
+
+func TestMergeWithUnexportedFields(t *testing.T) {
+	t.Parallel()
+
+	type inner struct {
+		n int
+		X int
+	}
+	type T struct {
+		Name    string
+		age     int
+		nick    string
+		in      inner
+		p       *int
+		Created time.Time
+	}
+
+	now := time.Now()
+	src := T{Name: "src", age: 42, nick: "src", in: inner{n: 1, X: 2}, p: New(3), Created: now}
+	cmpOpts := cmp.Options{cmp.AllowUnexported(T{}, inner{})}
+
+	if !CanExposeUnexported {
+		dst := T{}
+		if err := DeepMerge(&dst, src, WithUnexportedFields()); err == nil {
+			t.Error("want error got nil")
+		}
+		return
+	}
+
+	tests := []test{
+		{
+			name:    "default",
+			dst:     &T{nick: "dst"},
+			src:     T{Name: "src", age: 42, nick: "src", p: New(3), Created: now},
+			want:    &T{Name: "src", nick: "dst", Created: now},
+			cmpOpts: cmpOpts,
+		},
+		{
+			name:      "unexported fields",
+			dst:       &T{nick: "dst"},
+			src:       src,
+			mergeOpts: Options{WithUnexportedFields()},
+			want:      &T{Name: "src", age: 42, nick: "dst", in: inner{n: 1, X: 2}, p: New(3), Created: now},
+			cmpOpts:   cmpOpts,
+			check: func(t testing.TB, dst any) {
+				if dst.(*T).p == src.p {
+					t.Error("dst and src share p")
+				}
+			},
+		},
+		{
+			name:      "overwrite",
+			dst:       &T{age: 1, nick: "dst", in: inner{n: 5}},
+			src:       T{age: 2, in: inner{X: 6}},
+			mergeOpts: Options{WithUnexportedFields(), WithOverwrite()},
+			want:      &T{age: 2, nick: "dst", in: inner{n: 5, X: 6}},
+			cmpOpts:   cmpOpts,
+		},
+	}
+
+	t.Run("Merge", func(t *testing.T) { testDeepMerge(t, tests...) })
+
+	t.Run("Map", func(t *testing.T) { testDeepMap(t, tests...) })
+}
+
+func TestMergeWithUnexportedFieldsCopies(t *testing.T) {
+	t.Parallel()
+
+	if !CanExposeUnexported {
+		t.Skip("unexported fields need package unsafe")
+	}
+
+	type T struct {
+		Name string
+		m    map[string]int
+		s    []int
+	}
+
+	newDst := func() *T { return &T{Name: "dst", m: map[string]int{"x": 1}, s: []int{1}} }
+	src := T{m: map[string]int{"y": 2}, s: []int{2}}
+	opts := Options{WithUnexportedFields(), WithOverwrite(), WithSortedMapKeys()}
+	cmpOpts := cmp.Options{cmp.AllowUnexported(T{})}
+	merged := &T{Name: "dst", m: map[string]int{"x": 1, "y": 2}, s: []int{2}}
+
+	t.Run("DeepMergeNew", func(t *testing.T) {
+		dst := newDst()
+		got, err := DeepMergeNew(dst, src, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !cmp.Equal(newDst(), dst, cmpOpts) {
+			t.Error("dst modified:", cmp.Diff(newDst(), dst, cmpOpts))
+		}
+		if !cmp.Equal(merged, got, cmpOpts) {
+			t.Error(cmp.Diff(merged, got, cmpOpts))
+		}
+	})
+
+	t.Run("DryRun", func(t *testing.T) {
+		dst := newDst()
+		var changes []Change
+		if err := DeepMerge(dst, src, append(opts, WithDryRun(&changes))...); err != nil {
+			t.Fatal(err)
+		}
+		if !cmp.Equal(newDst(), dst, cmpOpts) {
+			t.Error("dst modified:", cmp.Diff(newDst(), dst, cmpOpts))
+		}
+		if len(changes) == 0 {
+			t.Error("no changes recorded")
+		}
+	})
+
+	t.Run("ApplyAndDiff", func(t *testing.T) {
+		dst := newDst()
+		changes, err := ApplyAndDiff(dst, src, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !cmp.Equal(merged, dst, cmpOpts) {
+			t.Error(cmp.Diff(merged, dst, cmpOpts))
+		}
+		want := []Change{{".m[y]", nil, 2}, {".s[0]", 1, 2}}
+		if !cmp.Equal(want, changes) {
+			t.Error(cmp.Diff(want, changes))
+		}
+	})
+}
+
+func TestMergeWithImmutableOnceSet(t *testing.T) {
+	t.Parallel()
+
+	type T struct {
+		Name   string
+		Port   int
+		Tags   []string
+		Labels map[string]string
+	}
+
+	layers := []T{
+		{Name: "first", Tags: []string{"a"}, Labels: map[string]string{"env": "prod"}},
+		{Name: "second", Port: 80, Tags: []string{"b"}, Labels: map[string]string{"env": "dev", "team": "x"}},
+		{Name: "third", Port: 8080, Labels: map[string]string{"team": "y"}},
+	}
+
+	assigned := make(map[string]bool)
+	var dst T
+	for _, layer := range layers {
+		if err := DeepMerge(&dst, layer, WithOverwrite(), WithAppendSlice(), WithImmutableOnceSet(assigned)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := T{Name: "first", Port: 80, Tags: []string{"a"}, Labels: map[string]string{"env": "prod", "team": "x"}}
+	if !cmp.Equal(want, dst) {
+		t.Error(cmp.Diff(want, dst))
+	}
+
+	t.Run("DryRun", func(t *testing.T) {
+		assigned := make(map[string]bool)
+		var changes []Change
+		if err := DeepMerge(&T{}, layers[0], WithImmutableOnceSet(assigned), WithDryRun(&changes)); err != nil {
+			t.Fatal(err)
+		}
+		if len(changes) == 0 || len(assigned) != 0 {
+			t.Errorf("got %d changes and assigned %v, want changes and no assigned paths", len(changes), assigned)
+		}
+	})
+
+	t.Run("AppendMapSlices", func(t *testing.T) {
+		assigned := make(map[string]bool)
+		dst := map[string][]int{}
+		for _, layer := range []map[string][]int{{"a": {1}}, {"a": {2}, "b": {3}}} {
+			if err := DeepMerge(dst, layer, WithAppendMapSlices(), WithImmutableOnceSet(assigned)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if want := map[string][]int{"a": {1}, "b": {3}}; !cmp.Equal(want, dst) {
+			t.Error(cmp.Diff(want, dst))
+		}
+	})
+
+	t.Run("MapConflict", func(t *testing.T) {
+		assigned := make(map[string]bool)
+		last := WithMapConflict(func(_, _, src reflect.Value) (reflect.Value, error) { return src, nil })
+		dst := map[string]int{}
+		for _, layer := range []map[string]int{{"a": 1}, {"a": 2}, {"a": 3}} {
+			if err := DeepMerge(dst, layer, last, WithImmutableOnceSet(assigned)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if want := map[string]int{"a": 1}; !cmp.Equal(want, dst) {
+			t.Error(cmp.Diff(want, dst))
+		}
+	})
+
+	t.Run("Map", func(t *testing.T) {
+		if err := DeepMap(&T{}, map[string]any{"name": "a"}, WithImmutableOnceSet(make(map[string]bool))); err == nil {
+			t.Error("want error got nil")
+		}
+	})
+}
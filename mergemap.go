@@ -0,0 +1,200 @@
+package merge
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// MergeMap deeply merges src into dst like DeepMerge, for the common case of
+// configuration trees decoded from JSON or YAML. Nested map[string]any and []any
+// values and scalars are merged directly rather than through reflection; other
+// values are merged like DeepMerge does.
+//
+// Only WithOverwrite, WithOverwriteWithEmptyValue, WithPruneMissingKeys and
+// WithTypeCheck are handled directly; with any other option MergeMap is the same as DeepMerge.
+func MergeMap(dst, src map[string]any, opts ...Option) error {
+	var c Config
+	Options(opts).apply(&c)
+	if err := c.validate(); err != nil {
+		return err
+	}
+	if dst == nil || src == nil || !c.mergeMapDirectly() {
+		return DeepMerge(dst, src, opts...)
+	}
+
+	m := &mapMerger{c: &c, visited: make(map[visit]string)}
+	if m.seen(reflect.ValueOf(src)) || valuePointer(reflect.ValueOf(dst)) == valuePointer(reflect.ValueOf(src)) {
+		return nil
+	}
+	return m.mergeMap("", dst, src)
+}
+
+// mergeMapDirectly reports whether c only sets options that MergeMap handles directly.
+func (c *Config) mergeMapDirectly() bool {
+	rest := *c
+	rest.overwrite, rest.overwriteWithEmptyValue, rest.pruneMissingKeys, rest.typeCheck = false, false, false, false
+	return reflect.DeepEqual(rest, Config{})
+}
+
+// mapMerger merges map[string]any values following the rules of deepValueMerge.
+type mapMerger struct {
+	c       *Config
+	visited map[visit]string // shared with deepValueMerge
+}
+
+// seen reports whether the map or slice v has been merged before,
+// and marks it as merged otherwise.
+func (m *mapMerger) seen(v reflect.Value) bool {
+	k := visit{valuePointer(v), v.Type()}
+	if m.visited[k] != "" {
+		return true
+	}
+	m.visited[k] = "MergeMap"
+	return false
+}
+
+func (m *mapMerger) mergeMap(path string, dst, src map[string]any) error {
+	for k, s := range src {
+		v, err := m.mergeAny(path+"["+k+"]", dst[k], s)
+		if err != nil {
+			return err
+		}
+		dst[k] = v
+	}
+
+	// Ensure that all keys in dst are deleted if they are not present in src.
+	if m.c.pruneMissingKeys {
+		for k := range dst {
+			if _, ok := src[k]; !ok {
+				delete(dst, k)
+			}
+		}
+	}
+	return nil
+}
+
+func (m *mapMerger) mergeSlice(path string, dst, src []any) ([]any, error) {
+	if len(dst) < len(src) {
+		if len(src) <= cap(dst) {
+			dst = dst[:len(src)]
+		} else {
+			s := make([]any, len(src))
+			copy(s, dst)
+			dst = s
+		}
+	}
+
+	if valuePointer(reflect.ValueOf(dst)) == valuePointer(reflect.ValueOf(src)) {
+		return dst, nil
+	}
+
+	for i := 0; i < len(dst) && i < len(src); i++ {
+		v, err := m.mergeAny(path+"["+strconv.Itoa(i)+"]", dst[i], src[i])
+		if err != nil {
+			return nil, err
+		}
+		dst[i] = v
+	}
+
+	// Ensure that all elements in dst are zeroed if src's len shorter than dst.
+	if m.c.overwriteWithEmptyValue {
+		for i := len(src); i < len(dst); i++ {
+			dst[i] = nil
+		}
+	}
+	return dst, nil
+}
+
+// mergeAny returns the result of merging the interface value src into dst.
+func (m *mapMerger) mergeAny(path string, dst, src any) (any, error) {
+	if src == nil {
+		// Ensure the value that dst contains is zeroed.
+		if dst != nil && m.c.overwriteWithEmptyValue && !reflect.ValueOf(dst).IsZero() {
+			return reflect.Zero(reflect.TypeOf(dst)).Interface(), nil
+		}
+		return dst, nil
+	}
+
+	if dst != nil && reflect.TypeOf(dst) != reflect.TypeOf(src) {
+		if !m.c.overwrite {
+			return m.mergeValue(path+"(interface {})", dst, src)
+		}
+		if m.c.typeCheck {
+			return nil, fmt.Errorf("%q: overwrite interface value with difference concrete type %T <- %T", path, dst, src)
+		}
+		return src, nil
+	}
+
+	path += "(interface {})"
+	switch s := src.(type) {
+	case map[string]any:
+		if s != nil && m.seen(reflect.ValueOf(s)) {
+			return s, nil
+		}
+
+		d, _ := dst.(map[string]any)
+		if d == nil {
+			if len(s) == 0 {
+				return d, nil
+			}
+			d = make(map[string]any, len(s))
+		} else if valuePointer(reflect.ValueOf(d)) == valuePointer(reflect.ValueOf(s)) {
+			return d, nil
+		}
+		return d, m.mergeMap(path, d, s)
+	case []any:
+		if s != nil && m.seen(reflect.ValueOf(s)) {
+			return s, nil
+		}
+
+		d, _ := dst.([]any)
+		return m.mergeSlice(path, d, s)
+	case string, bool, int, int64, uint64, float64:
+		if isZeroScalar(src) && !m.c.overwriteWithEmptyValue {
+			if dst == nil {
+				return src, nil
+			}
+			return dst, nil
+		}
+		if dst == nil || isZeroScalar(dst) || m.c.overwrite {
+			return src, nil
+		}
+		return dst, nil
+	}
+
+	if dst == nil {
+		dst = reflect.Zero(reflect.TypeOf(src)).Interface()
+	}
+	return m.mergeValue(path, dst, src)
+}
+
+// mergeValue merges src into dst using deepValueMerge.
+func (m *mapMerger) mergeValue(path string, dst, src any) (any, error) {
+	d := reflect.New(reflect.TypeOf(dst)).Elem()
+	d.Set(reflect.ValueOf(dst))
+	if err := deepValueMerge(path, d, reflect.ValueOf(src), m.visited, m.c); err != nil {
+		return nil, err
+	}
+	return d.Interface(), nil
+}
+
+// isZeroScalar is like reflect.Value.IsZero for the scalars handled by mapMerger.
+func isZeroScalar(v any) bool {
+	switch v := v.(type) {
+	case string:
+		return v == ""
+	case bool:
+		return !v
+	case int:
+		return v == 0
+	case int64:
+		return v == 0
+	case uint64:
+		return v == 0
+	case float64:
+		return math.Float64bits(v) == 0
+	}
+	return false
+}
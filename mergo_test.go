@@ -8,6 +8,7 @@ package merge_test
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	. "github.com/weiwenchen2022/merge"
 
@@ -314,6 +315,47 @@ func TestMapWithEmbeddedStructPointer(t *testing.T) {
 	t.Run("Map", func(t *testing.T) { testDeepMap(t, test) })
 }
 
+func TestMapWithStructPointerNoAliasing(t *testing.T) {
+	t.Parallel()
+
+	src := map[string]*T{"a": {1}, "b": {2}}
+	check := func(t testing.TB, a any) {
+		dst := a.(map[string]*T)
+		for k := range src {
+			if dst[k] == src[k] {
+				t.Errorf("dst[%q] and src[%q] alias", k, k)
+			}
+		}
+	}
+
+	tests := []test{
+		{
+			dst:   map[string]*T{"b": nil},
+			src:   src,
+			want:  map[string]*T{"a": {1}, "b": {2}},
+			check: check,
+		},
+		{
+			dst:       map[string]*T{"b": nil},
+			src:       src,
+			mergeOpts: Options{WithoutDereference()},
+			want:      map[string]*T{"a": {1}, "b": {2}},
+			check:     check,
+		},
+		{
+			dst:       map[string]*T{"b": nil},
+			src:       src,
+			mergeOpts: Options{WithoutDereference(), WithOverwrite()},
+			want:      map[string]*T{"a": {1}, "b": {2}},
+			check:     check,
+		},
+	}
+
+	t.Run("Merge", func(t *testing.T) { testDeepMerge(t, tests...) })
+
+	t.Run("Map", func(t *testing.T) { testDeepMap(t, tests...) })
+}
+
 func TestMergeUsingStructAndMap(t *testing.T) {
 	t.Parallel()
 
@@ -543,3 +585,52 @@ func TestMergeDifferentSlicesIsNotSupported(t *testing.T) {
 
 	t.Run("Map", func(t *testing.T) { testDeepMap(t, test) })
 }
+
+func TestOnlyUnexportedFields(t *testing.T) {
+	t.Parallel()
+
+	type lower struct{ a, b int }
+	type T struct {
+		l lower
+		t time.Time
+		A int
+	}
+
+	now := time.Now()
+	tests := []test{
+		{
+			name:    "skip",
+			dst:     &T{},
+			src:     T{lower{1, 2}, now, 3},
+			want:    &T{A: 3},
+			cmpOpts: cmp.Options{cmp.AllowUnexported(T{}, lower{})},
+		},
+		{
+			name:      "skip with overwrite",
+			dst:       &T{l: lower{1, 1}},
+			src:       T{lower{1, 2}, now, 3},
+			mergeOpts: Options{WithOverwrite()},
+			want:      &T{l: lower{1, 1}, A: 3},
+			cmpOpts:   cmp.Options{cmp.AllowUnexported(T{}, lower{})},
+		},
+		{
+			name:      "strict",
+			dst:       &T{},
+			src:       T{lower{1, 2}, now, 3},
+			mergeOpts: Options{WithStrictUnexported()},
+			wantErr:   true,
+		},
+		{
+			name:      "top level",
+			dst:       &lower{},
+			src:       lower{1, 2},
+			mergeOpts: Options{WithStrictUnexported()},
+			want:      &lower{1, 2},
+			cmpOpts:   cmp.Options{cmp.AllowUnexported(lower{})},
+		},
+	}
+
+	t.Run("Merge", func(t *testing.T) { testDeepMerge(t, tests...) })
+
+	t.Run("Map", func(t *testing.T) { testDeepMap(t, tests...) })
+}
@@ -0,0 +1,19 @@
+package merge
+
+// Must panics if err is non-nil. It is intended for use in variable
+// initializations and tests where merging known-good values cannot fail.
+func Must(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
+
+// MustMerge is like DeepMerge but panics if the merge fails.
+func MustMerge(dst, src any, opts ...Option) {
+	Must(DeepMerge(dst, src, opts...))
+}
+
+// MustMap is like DeepMap but panics if the map fails.
+func MustMap(dst, src any, opts ...Option) {
+	Must(DeepMap(dst, src, opts...))
+}
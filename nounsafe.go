@@ -0,0 +1,36 @@
+//go:build nounsafe
+
+// Build with the nounsafe tag where package unsafe is not allowed.
+
+package merge
+
+import "reflect"
+
+// pointer identifies the value a pointer, map or slice refers to.
+type pointer = uintptr
+
+func valuePointer(v reflect.Value) pointer {
+	return v.Pointer()
+}
+
+// canExposeUnexported reports whether exposeUnexported is available.
+const canExposeUnexported = false
+
+// exposeUnexported is not available without package unsafe.
+func exposeUnexported(v reflect.Value) reflect.Value {
+	panic("merge: exposeUnexported requires package unsafe")
+}
+
+// bytesToString returns the string of v, a slice of bytes.
+func bytesToString(v reflect.Value) reflect.Value {
+	return reflect.ValueOf(string(v.Bytes()))
+}
+
+// runesToString returns the string of v, a slice of runes.
+func runesToString(v reflect.Value) reflect.Value {
+	rs := make([]rune, v.Len())
+	for i := range rs {
+		rs[i] = rune(v.Index(i).Int())
+	}
+	return reflect.ValueOf(string(rs))
+}
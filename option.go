@@ -1,20 +1,106 @@
 package merge
 
-import "reflect"
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
 
 type Config struct {
 	overwrite               bool
 	overwriteWithEmptyValue bool
+	explicitEmpty           bool
+	pruneMissingKeys        bool
 	typeCheck               bool
+	defaultsOnly            bool
 	shouldNotDereference    bool
+	fillNilPointers         bool
+	initEmptyMaps           bool
+	strictUnexported        bool
+	preserveDynamicType     bool
 
-	appendSlice         bool
+	sliceStrategy       SliceStrategy
+	prependSlice        bool
 	overwriteEmptySlice bool
+	preferLongerSlice   bool
+	preserveCap         bool
+	copyOnGrow          bool
+	appendMapSlices     bool
 
-	transformers map[reflect.Type]reflect.Value
+	skipUnchangedMapWrites bool
+	channelPolicy          ChannelPolicy
+	nanPolicy              NaNPolicy
+	mapKeyStyle            KeyStyle
+	fieldAliases           map[string]string
+	sliceKeyField          string
+	unixTimeUnit           time.Duration
+	polarComplex           bool
+	truncateNumeric        bool
+	numericConvert         bool
+	interfaceConvert       bool
+	assertIdempotent       bool
+
+	dryRun        bool
+	changes       *[]Change
+	sortedMapKeys bool
+	onceSet       map[string]bool // from WithImmutableOnceSet, true for earlier merges
+
+	concatStrings bool
+	stringSep     string
+	numericAdd    bool
+
+	pathReducers map[string]Reducer
+	typeReducers map[reflect.Type]Reducer
+
+	roundFloats    bool
+	floatPlaces    int
+	floatTolerance float64
+
+	transformers      map[reflect.Type]reflect.Value
+	namedTransformers map[string]reflect.Value
+	elemTransformers  map[reflect.Type]reflect.Value
+	postProcessors    map[reflect.Type]func(reflect.Value) error
+	enums             map[reflect.Type]map[string]int64
+
+	fieldFilter        func(path string, field reflect.StructField) bool
+	partialTransformer func(dst, src reflect.Value) (bool, error)
+	emptyFunc          func(reflect.Value) bool
+	overwriteIf        func(dst, src reflect.Value) bool
+	mapConflict        func(key, dstVal, srcVal reflect.Value) (reflect.Value, error)
+	keyTransformer     func(reflect.Value) reflect.Value
+	tracer             func(TraceEvent)
+	allocator          Allocator
+	beforeHook         func(path string, dst, src reflect.Value)
+	afterHook          func(path string, dst, src reflect.Value)
+
+	scopes      []scope // from WithOptionsAt
+	parallelism int
+	nilSrcNoop  bool
+
+	unexportedFields bool
+	recoverPath      *string         // from WithRecover, the path being merged
+	mapFieldPaths    map[string]bool // from DeepMap, the logical paths of struct fields
+
+	// mapWriteHook, if set, is called after every write to a dst map.
+	// It is used for testing only.
+	mapWriteHook func(m, k, v reflect.Value)
 }
 
 // Option configures for specific behavior of DeepMerge and DeepMap.
+//
+// Merging returns an error, before modifying dst, if the options contradict each other:
+//
+//   - WithDefaultsOnly with an option that changes non-empty dst values
+//   - WithTypeCheck without WithOverwrite
+//   - WithFloatTolerance with a negative or NaN tolerance
+//   - WithPrependSlice with WithAppendSlice or another slice strategy
+//   - WithPreferLongerSlice with WithPrependSlice or a slice strategy
+//   - WithUnexportedFields in builds with the nounsafe tag
 type Option interface {
 	apply(c *Config)
 }
@@ -32,6 +118,30 @@ type option func(*Config)
 
 func (opt option) apply(c *Config) { opt(c) }
 
+// overwritingOption returns the name of an option applied to c that changes
+// non-empty dst values, or "" if there is none.
+func (c *Config) overwritingOption() string {
+	switch {
+	case c.overwriteIf != nil:
+		return "WithOverwriteIf"
+	case c.typeCheck:
+		return "WithTypeCheck"
+	case c.overwriteWithEmptyValue:
+		return "WithOverwriteWithEmptyValue"
+	case c.overwrite:
+		return "WithOverwrite"
+	case c.numericAdd:
+		return "WithNumericAdd"
+	case c.concatStrings:
+		return "WithStringConcat"
+	case c.pruneMissingKeys:
+		return "WithPruneMissingKeys"
+	case SliceReplace == c.sliceStrategy, SliceTruncate == c.sliceStrategy:
+		return "the SliceReplace or SliceTruncate slice strategy"
+	}
+	return ""
+}
+
 // WithOverwrite make merge overwrite non-empty dst attributes with non-empty src attributes values.
 func WithOverwrite() Option {
 	return option(func(c *Config) { c.overwrite = true })
@@ -45,20 +155,298 @@ func WithOverwriteWithEmptyValue() Option {
 	})
 }
 
+// WithPruneMissingKeys make merge delete the keys of dst maps that are not present
+// in the src maps. It is independent of WithOverwriteWithEmptyValue, which only
+// overwrites dst values with empty src values.
+func WithPruneMissingKeys() Option {
+	return option(func(c *Config) { c.pruneMissingKeys = true })
+}
+
+// WithExplicitEmpty make merge treat an empty string, number or bool present in a src
+// map[string]any like any other value, so that JSON {"name":""} clears the name
+// while {} leaves it alone. Other empty src values are still skipped.
+func WithExplicitEmpty() Option {
+	return option(func(c *Config) { c.explicitEmpty = true })
+}
+
+// forMapValue returns the Config to merge the value v of the src map m with.
+// With WithExplicitEmpty, an empty scalar in a map[string]any is merged
+// as if by WithOverwriteWithEmptyValue.
+func (c *Config) forMapValue(m, v reflect.Value) *Config {
+	if !c.explicitEmpty || c.overwriteWithEmptyValue || !isAnyMap(m.Type()) {
+		return c
+	}
+	if reflect.Interface == v.Kind() {
+		v = v.Elem()
+	}
+	if !v.IsValid() || !isEmptyScalar(v) {
+		return c
+	}
+
+	ec := *c
+	ec.overwriteWithEmptyValue = true
+	return &ec
+}
+
+// isAnyMap reports whether t is a map[string]any, possibly named.
+func isAnyMap(t reflect.Type) bool {
+	return reflect.Map == t.Kind() && reflect.String == t.Key().Kind() &&
+		reflect.Interface == t.Elem().Kind() && t.Elem().NumMethod() == 0
+}
+
+// isEmptyScalar reports whether v is an empty string, number or bool.
+func isEmptyScalar(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return v.IsZero()
+	}
+	return false
+}
+
+// WithDefaultsOnly make merge only fill empty dst values from src, never overwriting
+// non-empty ones, for safely applying defaults at library boundaries. Merging returns
+// an error if it is used with WithOverwrite, WithOverwriteWithEmptyValue, WithTypeCheck,
+// WithOverwriteIf, WithNumericAdd, WithStringConcat, WithPruneMissingKeys or the
+// SliceReplace and SliceTruncate slice strategies, including those scoped by WithOptionsAt.
+func WithDefaultsOnly() Option {
+	return option(func(c *Config) { c.defaultsOnly = true })
+}
+
+// WithSrcWins make merge keep src values over non-empty dst values.
+// It is the same as WithOverwrite, named for readability at call sites.
+func WithSrcWins() Option {
+	return WithOverwrite()
+}
+
+// WithDstWins make merge keep non-empty dst values over src values, which is the default,
+// but explicitly. It is the same as WithDefaultsOnly, so merging returns an error if it is
+// used with WithSrcWins or the other overwriting options.
+func WithDstWins() Option {
+	return WithDefaultsOnly()
+}
+
 // WithTypeCheck make merge check types while overwriting it (must be used with WithOverwrite).
+// Types of the same kind that convert to each other, such as time.Duration and int64
+// or a named float64 type and float64, pass the check.
 func WithTypeCheck() Option {
 	return option(func(c *Config) { c.typeCheck = true })
 }
 
 // WithoutDereference prevents dereferencing pointers when evaluating whether they are empty
-// (i.e. a non-nil pointer is never considered empty).
+// (i.e. a non-nil pointer is never considered empty). Pointers to a type with
+// a transformer are still dereferenced, and what they point to transformed.
 func WithoutDereference() Option {
 	return option(func(c *Config) { c.shouldNotDereference = true })
 }
 
+// WithFillNilPointers make merge set nil dst pointers to a deep copy of what non-nil
+// src pointers point to, and leave non-nil dst pointers, and what they point to, as is.
+// It takes precedence over WithOverwrite, WithOverwriteWithEmptyValue and
+// WithoutDereference for pointers; other values are merged as usual.
+func WithFillNilPointers() Option {
+	return option(func(c *Config) { c.fillNilPointers = true })
+}
+
+// WithInitEmptyMaps make merge set a nil dst map to an empty non-nil map
+// when the corresponding src map is empty but non-nil.
+func WithInitEmptyMaps() Option {
+	return option(func(c *Config) { c.initEmptyMaps = true })
+}
+
+// ErrNilDst is returned by DeepMerge and DeepMap for a nil dst with WithNilSrcNoop.
+var ErrNilDst = errors.New("dst is nil")
+
+// WithNilSrcNoop make DeepMerge and DeepMap do nothing and return no error when src
+// is nil or a nil pointer, as for an optional src in generic code. A nil dst, or a nil
+// dst pointer, is then reported as ErrNilDst.
+func WithNilSrcNoop() Option {
+	return option(func(c *Config) { c.nilSrcNoop = true })
+}
+
+// WithUnexportedFields make merge also merge the unexported fields of structs that have
+// exported fields, as if they were exported. Structs with only unexported fields, such as
+// time.Time, are still merged as a whole.
+//
+// It uses package unsafe to modify unexported fields, bypassing the invariants that
+// their package maintains: a merged value may hold aliased internal state, such as
+// a mutex or a cache, that its methods do not expect. Only use it for plain data
+// types whose unexported fields are safe to copy. It is an error in builds with the
+// nounsafe tag.
+func WithUnexportedFields() Option {
+	return option(func(c *Config) { c.unexportedFields = true })
+}
+
+// exposeFields reports whether the unexported fields of the struct dst, with fields fs,
+// are merged, see WithUnexportedFields. If so, it returns src made addressable.
+func (c *Config) exposeFields(fs *structFields, dst, src reflect.Value) (reflect.Value, bool) {
+	if !c.unexportedFields || !fs.mergeable || !dst.CanAddr() {
+		return src, false
+	}
+	if !src.CanAddr() {
+		cp := reflect.New(src.Type()).Elem()
+		cp.Set(src)
+		src = cp
+	}
+	return src, true
+}
+
+// checkNil reports whether merging src into dst is a no-op because src is nil,
+// or an error if dst or src is nil.
+func (c *Config) checkNil(dst, src any) (bool, error) {
+	if !c.nilSrcNoop {
+		if dst == nil || src == nil {
+			return false, errors.New("dst or src is nil")
+		}
+		return false, nil
+	}
+	if isNilOrNilPointer(dst) {
+		return false, ErrNilDst
+	}
+	return isNilOrNilPointer(src), nil
+}
+
+func isNilOrNilPointer(v any) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	return reflect.Pointer == rv.Kind() && rv.IsNil()
+}
+
+// WithRecover make DeepMerge and DeepMap, and MergeValue and MapValue, return an error
+// instead of panicking, e.g. on unexpected input or in a transformer or hook.
+// The error is annotated with the path of the value being merged when it panicked,
+// and wraps the panic value if it is an error. It disables WithParallelism.
+func WithRecover() Option {
+	return option(func(c *Config) { c.recoverPath = new(string) })
+}
+
+// enter records path as the path being merged for WithRecover.
+func (c *Config) enter(path string) {
+	if c.recoverPath != nil {
+		*c.recoverPath = path
+	}
+}
+
+// recoverPanic converts a panic into *err with WithRecover. It must be deferred.
+func (c *Config) recoverPanic(err *error) {
+	if c.recoverPath == nil {
+		return
+	}
+	if r := recover(); r != nil {
+		if e, ok := r.(error); ok {
+			*err = fmt.Errorf("%q: panic: %w", *c.recoverPath, e)
+		} else {
+			*err = fmt.Errorf("%q: panic: %v", *c.recoverPath, r)
+		}
+	}
+}
+
+// WithStrictUnexported make merge return an error instead of silently skipping
+// a struct value with only unexported fields, such as time.Time, that can not
+// be assigned because it is held in an unexported field.
+func WithStrictUnexported() Option {
+	return option(func(c *Config) { c.strictUnexported = true })
+}
+
+// WithPreserveDstDynamicType make merge keep the dynamic type of non-nil interface
+// values in dst when overwriting them with src values of a different concrete type,
+// by converting the src value to it. It is an error if the src value is not convertible.
+// Without it, such dst values adopt both the value and the dynamic type of src.
+func WithPreserveDstDynamicType() Option {
+	return option(func(c *Config) { c.preserveDynamicType = true })
+}
+
+// SliceStrategy controls how src slices are merged into dst slices.
+type SliceStrategy int
+
+const (
+	// SliceMergeByIndex merges the elements of src into the elements of dst with
+	// the same index, growing dst to the length of src if shorter. It is the default.
+	SliceMergeByIndex SliceStrategy = iota
+
+	// SliceReplace makes dst a deep copy of src, regardless of WithOverwrite.
+	// An empty src only replaces dst with WithOverwriteWithEmptyValue.
+	SliceReplace
+
+	// SliceAppend appends the elements of src to dst.
+	SliceAppend
+
+	// SliceAppendUnique appends the elements of src to dst that are not deeply equal
+	// to an element of dst or to an earlier element of src. It compares every pair
+	// of elements, so it is meant for short slices such as lists of tags.
+	SliceAppendUnique
+
+	// SliceTruncate is like SliceMergeByIndex but also shrinks dst slices longer
+	// than src to the length of src, so that the result has exactly the length of src.
+	SliceTruncate
+)
+
+// WithSliceStrategy make merge merge slices following s. It replaces any slice strategy
+// set by earlier options, such as WithAppendSlice.
+func WithSliceStrategy(s SliceStrategy) Option {
+	return option(func(c *Config) { c.sliceStrategy = s })
+}
+
+// concatSlices reports whether src slices are added to dst slices
+// rather than merged into them element by element.
+func (c *Config) concatSlices() bool {
+	return SliceAppend == c.sliceStrategy || SliceAppendUnique == c.sliceStrategy || c.prependSlice
+}
+
 // WithAppendSlice make merge append slices instead of overwriting it.
+// It is the same as WithSliceStrategy(SliceAppend).
 func WithAppendSlice() Option {
-	return option(func(c *Config) { c.appendSlice = true })
+	return WithSliceStrategy(SliceAppend)
+}
+
+// WithPrependSlice make merge prepend src slices to dst slices instead of overwriting it.
+// It can not be used with WithAppendSlice or another slice strategy.
+func WithPrependSlice() Option {
+	return option(func(c *Config) { c.prependSlice = true })
+}
+
+// WithPreferLongerSlice make merge result in the longer of the dst and src slices:
+// their elements are merged up to the shorter length, and the remaining elements
+// are taken from the longer one. Unlike WithAppendSlice no elements are duplicated,
+// and dst elements beyond the length of src are kept even with
+// WithOverwriteWithEmptyValue, which otherwise zeroes them. It can not be used with
+// WithPrependSlice or a slice strategy.
+func WithPreferLongerSlice() Option {
+	return option(func(c *Config) { c.preferLongerSlice = true })
+}
+
+// WithSliceTruncate make merge shrink dst slices longer than src to the length of src,
+// dropping the remaining dst elements, so that the result has exactly the length of src.
+// Unlike WithOverwriteWithEmptyValue, which zeroes those elements, it changes the length.
+// It is the same as WithSliceStrategy(SliceTruncate).
+func WithSliceTruncate() Option {
+	return WithSliceStrategy(SliceTruncate)
+}
+
+// WithPreserveCap make merge grow dst slices shorter than src like append does,
+// leaving spare capacity, instead of to exactly the length of src. Repeated merges
+// of growing src slices into the same dst then reuse its capacity.
+func WithPreserveCap() Option {
+	return option(func(c *Config) { c.preserveCap = true })
+}
+
+// WithCopyOnGrow make merge allocate a new backing array for dst slices shorter than src
+// even when their capacity would fit src, so that elements beyond the length of dst,
+// which may be shared through other slices of the same array, are not overwritten.
+// With WithPreserveCap the new array keeps the capacity of dst.
+func WithCopyOnGrow() Option {
+	return option(func(c *Config) { c.copyOnGrow = true })
+}
+
+// WithAppendMapSlices make merge append the src slice to the dst slice when a key is
+// present in both maps and its values are slices of the same type, like WithAppendSlice
+// does for all slices. Slices outside of maps are merged as usual.
+func WithAppendMapSlices() Option {
+	return option(func(c *Config) { c.appendMapSlices = true })
 }
 
 // WithOverwriteEmptySlice will make merge override empty dst slice with empty src slice.
@@ -66,14 +454,732 @@ func WithOverwriteEmptySlice() Option {
 	return option(func(c *Config) { c.overwriteEmptySlice = true })
 }
 
+// WithSkipUnchangedMapWrites make merge skip writing a map entry back to dst
+// when the merged value equals the existing one. Values that are not comparable
+// are always written.
+func WithSkipUnchangedMapWrites() Option {
+	return option(func(c *Config) { c.skipUnchangedMapWrites = true })
+}
+
+// scope holds the options of WithOptionsAt.
+type scope struct {
+	prefix string
+	opts   Options
+}
+
+// WithOptionsAt make merge apply opts, on top of the other options, to the value at
+// pathPrefix and everything below it, e.g. to append slices under ".Tags" but
+// overwrite ports under ".Config.Ports".
+//
+// Paths are written as DeepMerge reports them, with ".Field", "[index]" and "[key]"
+// selectors, e.g. ".Config.Hosts[0]" or ".Labels[app]"; DeepMap names fields
+// "[Field]" instead, e.g. "[Config][Hosts][0]". Pointer dereferences and interface
+// values are transparent, so ".Config.Ports" matches even if Config is a pointer.
+// A prefix matches whole selectors only: ".Tag" matches ".Tag" and ".Tag[0]"
+// but not ".Tags". When several prefixes match, their options are applied in order.
+func WithOptionsAt(pathPrefix string, opts ...Option) Option {
+	return option(func(c *Config) {
+		c.scopes = append(c.scopes, scope{pathPrefix, opts})
+	})
+}
+
+// at returns the Config to merge the value at path and below with: c with the options
+// of the scopes whose prefix matches path applied. The matched scopes are removed
+// from the returned Config, so that they are applied once per subtree.
+func (c *Config) at(path string) (*Config, error) {
+	lp := logicalPath(path)
+	var match bool
+	for _, s := range c.scopes {
+		if matchPathPrefix(lp, s.prefix) {
+			match = true
+			break
+		}
+	}
+	if !match {
+		return c, nil
+	}
+
+	sc := c.clone()
+	sc.scopes = nil
+	var matched []scope
+	for _, s := range c.scopes {
+		if matchPathPrefix(lp, s.prefix) {
+			matched = append(matched, s)
+		} else {
+			sc.scopes = append(sc.scopes, s)
+		}
+	}
+	for _, s := range matched {
+		s.opts.apply(sc)
+	}
+	if err := sc.validate(); err != nil {
+		return nil, fmt.Errorf("%q: %w", path, err)
+	}
+	return sc, nil
+}
+
+// matchPathPrefix reports whether prefix is path or selects an ancestor of it.
+func matchPathPrefix(path, prefix string) bool {
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	return len(path) == len(prefix) || prefix == "" || '.' == path[len(prefix)] || '[' == path[len(prefix)]
+}
+
+// logicalPath returns path without the pointer dereferences, as in "(*.A).B",
+// and interface types, as in ".A(interface {})", that merge paths contain.
+func logicalPath(path string) string {
+	if !strings.Contains(path, "(") {
+		return path
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		switch ch := path[i]; {
+		case '[' == ch:
+			// Copy the index or key as is.
+			j := strings.IndexByte(path[i:], ']')
+			if j < 0 {
+				j = len(path) - i - 1
+			}
+			b.WriteString(path[i : i+j+1])
+			i += j
+		case '(' == ch && i+1 < len(path) && '*' == path[i+1]:
+			i++
+		case '(' == ch:
+			// Skip the interface type up to the matching parenthesis.
+			for n := 0; i < len(path); i++ {
+				if '(' == path[i] {
+					n++
+				} else if ')' == path[i] {
+					if n--; n == 0 {
+						break
+					}
+				}
+			}
+		case ')' == ch:
+		default:
+			b.WriteByte(ch)
+		}
+	}
+	return b.String()
+}
+
+// clone returns a copy of c that can be modified without affecting c.
+func (c *Config) clone() *Config {
+	cc := *c
+	cc.fieldAliases = cloneMap(c.fieldAliases)
+	cc.pathReducers = cloneMap(c.pathReducers)
+	cc.typeReducers = cloneMap(c.typeReducers)
+	cc.transformers = cloneMap(c.transformers)
+	cc.namedTransformers = cloneMap(c.namedTransformers)
+	cc.elemTransformers = cloneMap(c.elemTransformers)
+	cc.postProcessors = cloneMap(c.postProcessors)
+	cc.enums = cloneMap(c.enums)
+	return &cc
+}
+
+func cloneMap[K comparable, V any](m map[K]V) map[K]V {
+	if m == nil {
+		return nil
+	}
+	r := make(map[K]V, len(m))
+	for k, v := range m {
+		r[k] = v
+	}
+	return r
+}
+
+// WithFieldFilter make merge consult fn for every struct field before merging it;
+// the field is skipped when fn returns false. path is the full path of the field,
+// so filters can be scoped to nested structs.
+func WithFieldFilter(fn func(path string, field reflect.StructField) bool) Option {
+	return option(func(c *Config) { c.fieldFilter = fn })
+}
+
+// WithFieldAlias make merge match the fields of structs of different types by name,
+// mapping each src field named in aliases to the dst field named by its value, e.g.
+// {"Name": "Title"} merges A.Name into B.Title. Other src fields go to the dst field
+// of the same name, if any. Without it, such structs are matched field by field in order.
+func WithFieldAlias(aliases map[string]string) Option {
+	return option(func(c *Config) {
+		if c.fieldAliases == nil {
+			c.fieldAliases = make(map[string]string, len(aliases))
+		}
+		for k, v := range aliases {
+			c.fieldAliases[k] = v
+		}
+	})
+}
+
+// WithStringConcat make merge append non-empty src strings to non-empty dst strings,
+// separated by sep, instead of keeping or overwriting dst.
+// For strings it takes precedence over WithOverwrite and WithOverwriteWithEmptyValue.
+func WithStringConcat(sep string) Option {
+	return option(func(c *Config) {
+		c.concatStrings = true
+		c.stringSep = sep
+	})
+}
+
+// WithNumericAdd make merge set numeric dst values to the sum of dst and src
+// instead of keeping or overwriting dst. Overflow is reported as an error.
+func WithNumericAdd() Option {
+	return option(func(c *Config) { c.numericAdd = true })
+}
+
+// Reducer is a way of combining numeric dst and src values.
+type Reducer int
+
+const (
+	ReduceSum Reducer = iota + 1 // dst + src
+	ReduceMax                    // the greater of dst and src
+	ReduceMin                    // the lesser of dst and src
+)
+
+// WithNumericReduce make merge combine numeric dst and src values with r instead
+// of keeping or overwriting dst. target selects the values: either a string path,
+// such as ".Stats.Max", or a reflect.Type; paths take precedence over types.
+// An empty dst value is always set to src for ReduceMax and ReduceMin,
+// so the first merge into a zero dst takes src as is.
+func WithNumericReduce(target any, r Reducer) Option {
+	return option(func(c *Config) {
+		switch target := target.(type) {
+		case string:
+			if c.pathReducers == nil {
+				c.pathReducers = make(map[string]Reducer)
+			}
+			c.pathReducers[target] = r
+		case reflect.Type:
+			if c.typeReducers == nil {
+				c.typeReducers = make(map[reflect.Type]Reducer)
+			}
+			c.typeReducers[target] = r
+		default:
+			panic("target must be a string path or a reflect.Type")
+		}
+	})
+}
+
+// WithFloatRound make merge round float values set into dst to places decimal places.
+func WithFloatRound(places int) Option {
+	return option(func(c *Config) {
+		c.roundFloats = true
+		c.floatPlaces = places
+	})
+}
+
+// WithFloatTolerance make merge leave a non-empty float dst alone when src is within eps
+// of it, that is when math.Abs(dst-src) <= eps, even with WithOverwrite, to avoid churn
+// when layering floats. An empty dst is still set. Reducers such as ReduceSum are not affected.
+// A negative or NaN eps is an error.
+func WithFloatTolerance(eps float64) Option {
+	return option(func(c *Config) { c.floatTolerance = eps })
+}
+
+// WithSliceToMapByKey make DeepMap map slices of structs into maps of the same
+// element type, keyed by the value of the named struct field.
+func WithSliceToMapByKey(field string) Option {
+	return option(func(c *Config) { c.sliceKeyField = field })
+}
+
+// WithAssertIdempotent make merge check, after merging, that merging the same src
+// into the result again would not change it, and return an error otherwise.
+// The second merge is done on a copy, so dst is not modified by it.
+// It is intended for testing merge configurations.
+func WithAssertIdempotent() Option {
+	return option(func(c *Config) { c.assertIdempotent = true })
+}
+
+// WithUnixTimeFields make DeepMap map integer src values into time.Time dst values
+// as Unix timestamps in the given unit, which must be one of time.Second,
+// time.Millisecond, time.Microsecond or time.Nanosecond.
+func WithUnixTimeFields(unit time.Duration) Option {
+	switch unit {
+	case time.Second, time.Millisecond, time.Microsecond, time.Nanosecond:
+	default:
+		panic("WithUnixTimeFields called with invalid unit " + unit.String())
+	}
+	return option(func(c *Config) { c.unixTimeUnit = unit })
+}
+
+// WithPolarComplex make DeepMap map structs and maps with Magnitude and Phase
+// fields or keys into complex dst values, as computed by cmplx.Rect.
+func WithPolarComplex() Option {
+	return option(func(c *Config) { c.polarComplex = true })
+}
+
+// Change describes an assignment made by DeepMerge.
+type Change struct {
+	Path     string // path of the assigned value, e.g. ".Metadata.Created"
+	Old, New any    // New is nil if a map key is deleted
+}
+
+// WithDryRun make DeepMerge and DeepMap record every assignment they would make in record,
+// without modifying dst.
+func WithDryRun(record *[]Change) Option {
+	return option(func(c *Config) {
+		c.dryRun = true
+		c.changes = record
+	})
+}
+
+// WithSortedMapKeys make merge, Diff and ApplyAndDiff visit the keys of every map in
+// sorted order, so that the changes reported by WithDryRun, ApplyAndDiff and Diff and
+// the events traced by WithTracer are identical across runs regardless of map iteration
+// order. Keys are sorted the way fmt prints maps, numbers by value, so that ".M[9]"
+// comes before ".M[10]"; struct fields are still visited in declaration order.
+func WithSortedMapKeys() Option {
+	return option(func(c *Config) { c.sortedMapKeys = true })
+}
+
+// WithImmutableOnceSet make DeepMerge record the path of every assignment in assigned,
+// like the Path of a Change, and leave alone the values at paths that an earlier merge
+// with the same assigned map recorded, even with WithOverwrite. Sharing assigned across
+// the merges of layered configs makes the first layer that sets a value win:
+//
+//	assigned := make(map[string]bool)
+//	for _, layer := range layers {
+//		if err := merge.DeepMerge(&cfg, layer, merge.WithOverwrite(), merge.WithImmutableOnceSet(assigned)); err != nil {
+//			return err
+//		}
+//	}
+//
+// A value assigned as a whole, such as a nil dst slice set to src or a key added to a map,
+// has its own path: appending to it or resolving a map conflict for it later is refused,
+// but an element assigned on its own is not protected by the path of its container. Paths are only recorded once a merge returns, so a merge can
+// assign a path more than once. WithDryRun records nothing. DeepMap does not support it.
+func WithImmutableOnceSet(assigned map[string]bool) Option {
+	if assigned == nil {
+		panic("WithImmutableOnceSet called with a nil map")
+	}
+	return option(func(c *Config) { c.onceSet = assigned })
+}
+
+// assignOnce reports whether the value at path may be assigned under WithImmutableOnceSet,
+// recording path for the end of the merge.
+func (c *Config) assignOnce(path string) bool {
+	if c.onceSet == nil {
+		return true
+	}
+	if c.onceSet[path] {
+		return false
+	}
+	if !c.dryRun {
+		c.onceSet[path] = false
+	}
+	return true
+}
+
+// commitOnceSet marks the paths assigned by the current merge as set by an earlier merge
+// for the next one.
+func (c *Config) commitOnceSet() {
+	for path, done := range c.onceSet {
+		if !done {
+			c.onceSet[path] = true
+		}
+	}
+}
+
+// mapIter is like reflect.MapIter, but iterates in the order of sorted keys
+// under WithSortedMapKeys.
+type mapIter struct {
+	m    reflect.Value
+	it   *reflect.MapIter
+	keys []reflect.Value // sorted, if it is nil
+	i    int
+}
+
+// mapRange is like m.MapRange, see mapIter.
+func (c *Config) mapRange(m reflect.Value) *mapIter {
+	if !c.sortedMapKeys {
+		return &mapIter{m: m, it: m.MapRange()}
+	}
+	keys := m.MapKeys()
+	sort.SliceStable(keys, func(i, j int) bool { return compareKeys(keys[i], keys[j]) < 0 })
+	return &mapIter{m: m, keys: keys, i: -1}
+}
+
+func (it *mapIter) Next() bool {
+	if it.it != nil {
+		return it.it.Next()
+	}
+	it.i++
+	return it.i < len(it.keys)
+}
+
+func (it *mapIter) Key() reflect.Value {
+	if it.it != nil {
+		return it.it.Key()
+	}
+	return it.keys[it.i]
+}
+
+func (it *mapIter) Value() reflect.Value {
+	if it.it != nil {
+		return it.it.Value()
+	}
+	return it.m.MapIndex(it.keys[it.i])
+}
+
+// compareKeys orders the map keys a and b like fmt does when printing maps:
+// numbers, strings and bools by value, pointers and channels by address,
+// structs and arrays element by element, and interfaces by their type first.
+func compareKeys(a, b reflect.Value) int {
+	if a.Type() != b.Type() {
+		return compareOrdered(a.Type().String(), b.Type().String())
+	}
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return compareOrdered(a.Int(), b.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return compareOrdered(a.Uint(), b.Uint())
+	case reflect.Float32, reflect.Float64:
+		return compareOrdered(a.Float(), b.Float())
+	case reflect.Complex64, reflect.Complex128:
+		if c := compareOrdered(real(a.Complex()), real(b.Complex())); c != 0 {
+			return c
+		}
+		return compareOrdered(imag(a.Complex()), imag(b.Complex()))
+	case reflect.String:
+		return compareOrdered(a.String(), b.String())
+	case reflect.Bool:
+		switch {
+		case a.Bool() == b.Bool():
+			return 0
+		case b.Bool():
+			return -1
+		}
+		return 1
+	case reflect.Pointer, reflect.Chan, reflect.UnsafePointer:
+		return compareOrdered(a.Pointer(), b.Pointer())
+	case reflect.Struct:
+		for i := 0; i < a.NumField(); i++ {
+			if c := compareKeys(a.Field(i), b.Field(i)); c != 0 {
+				return c
+			}
+		}
+		return 0
+	case reflect.Array:
+		for i := 0; i < a.Len(); i++ {
+			if c := compareKeys(a.Index(i), b.Index(i)); c != 0 {
+				return c
+			}
+		}
+		return 0
+	case reflect.Interface:
+		switch {
+		case a.IsNil() && b.IsNil():
+			return 0
+		case a.IsNil():
+			return -1
+		case b.IsNil():
+			return 1
+		}
+		return compareKeys(a.Elem(), b.Elem())
+	}
+	return 0
+}
+
+// compareOrdered returns -1, 0 or +1 as a is less than, equal to or greater than b.
+// NaNs are less than other numbers, like for fmt.
+func compareOrdered[T int64 | uint64 | uintptr | float64 | string](a, b T) int {
+	switch {
+	case a < b, a != a && b == b:
+		return -1
+	case a > b, a == a && b != b:
+		return 1
+	}
+	return 0
+}
+
+// WithNumericConvert make DeepMerge merge numbers of different kinds, e.g. an int src
+// into an int32 dst, by converting them like DeepMap does: it is an error if the src
+// value can not be represented exactly in the dst type, unless WithTruncateNumeric is set.
+func WithNumericConvert() Option {
+	return option(func(c *Config) { c.numericConvert = true })
+}
+
+// WithInterfaceConvert make DeepMap convert a src value into the concrete type held
+// by a non-nil dst interface value when their kinds differ but the src type is
+// convertible to it, e.g. an int into an int64, instead of replacing the dst value
+// under WithOverwrite or leaving it alone otherwise. Numbers must be representable
+// in the dst type. WithTypeCheck takes precedence: with it overwriting a concrete
+// type with a different one is still an error.
+func WithInterfaceConvert() Option {
+	return option(func(c *Config) { c.interfaceConvert = true })
+}
+
+// WithTruncateNumeric make DeepMap convert numbers that can not be represented
+// exactly in the dst type instead of returning an error: floating-point values
+// mapped into integers are truncated toward zero, and integers mapped into
+// floating-point values are rounded to the nearest representable value.
+// Values outside the range of the dst type are still an error.
+func WithTruncateNumeric() Option {
+	return option(func(c *Config) { c.truncateNumeric = true })
+}
+
+// WithEmptyFunc make merge use fn instead of reflect.Value.IsZero to decide
+// whether a value is empty, e.g. to treat sql.NullString{Valid: false} as empty.
+// Struct values that fn reports as empty are merged as a whole rather than field by field.
+func WithEmptyFunc(fn func(reflect.Value) bool) Option {
+	return option(func(c *Config) { c.emptyFunc = fn })
+}
+
+// ChannelPolicy controls how channel values are merged.
+type ChannelPolicy int
+
+const (
+	// ChannelKeep merges channels like other values: a nil dst channel is set
+	// to src, and WithOverwrite replaces non-nil dst channels too. It is the default.
+	ChannelKeep ChannelPolicy = iota
+
+	// ChannelOverwriteNonNil only sets a nil dst channel to a non-nil src channel,
+	// regardless of WithOverwrite and WithOverwriteWithEmptyValue.
+	ChannelOverwriteNonNil
+)
+
+// WithChannelPolicy make merge use p for channel values.
+func WithChannelPolicy(p ChannelPolicy) Option {
+	return option(func(c *Config) { c.channelPolicy = p })
+}
+
+// NaNPolicy controls how NaN src floating-point values are merged.
+type NaNPolicy int
+
+const (
+	// NaNAllow merges NaN like any other non-empty value. It is the default.
+	NaNAllow NaNPolicy = iota
+
+	// NaNSkip treats NaN as an empty value that never overwrites dst,
+	// for data where NaN means unset.
+	NaNSkip
+
+	// NaNError makes merge return an error for NaN src values.
+	NaNError
+)
+
+// WithNaNPolicy make merge use p for NaN src floating-point values.
+func WithNaNPolicy(p NaNPolicy) Option {
+	return option(func(c *Config) { c.nanPolicy = p })
+}
+
+// checkNaN applies the NaN policy to the floating-point src value at path.
+// It reports whether src is NaN and must not be merged.
+func (c *Config) checkNaN(path string, src reflect.Value) (bool, error) {
+	if NaNAllow == c.nanPolicy || !math.IsNaN(src.Float()) {
+		return false, nil
+	}
+	if NaNError == c.nanPolicy {
+		return true, fmt.Errorf("%q: NaN src value", path)
+	}
+	return true, nil
+}
+
+// KeyStyle controls the keys DeepMap writes for struct fields into maps.
+type KeyStyle int
+
+const (
+	// KeyStyleCamel writes field names in lower camel case, e.g. fieldName. It is the default.
+	KeyStyleCamel KeyStyle = iota
+
+	// KeyStyleSnake writes field names in snake case, e.g. field_name and http_server.
+	KeyStyleSnake
+
+	// KeyStyleExact writes field names as they are declared, e.g. FieldName.
+	KeyStyleExact
+)
+
+// WithMapKeyStyle make DeepMap use style for the keys of struct fields mapped into maps.
+// An existing key equal to the field name is always used as is.
+func WithMapKeyStyle(style KeyStyle) Option {
+	return option(func(c *Config) { c.mapKeyStyle = style })
+}
+
+// WithOverwriteIf make merge overwrite non-empty dst values with src values only
+// when fn returns true, e.g. to keep the greater of two numbers or the newer
+// of two timestamps. It applies to values merged as a whole, such as numbers,
+// strings and bools, and takes the place of WithOverwrite for them.
+func WithOverwriteIf(fn func(dst, src reflect.Value) bool) Option {
+	return option(func(c *Config) { c.overwriteIf = fn })
+}
+
+// WithMapConflict make merge call fn for every key present in both a dst map
+// and a src map, instead of deeply merging the two values. If fn returns an invalid
+// reflect.Value the dst value is kept, otherwise the returned value is set,
+// which allows strategies such as last write wins, min/max or concatenation.
+func WithMapConflict(fn func(key, dstVal, srcVal reflect.Value) (reflect.Value, error)) Option {
+	return option(func(c *Config) { c.mapConflict = fn })
+}
+
+// WithKeyTransformer make merge match map keys by their normalized form fn(key),
+// e.g. to merge "Port" and "port" with fn lowering strings. The keys of dst maps
+// are replaced with their normalized form before the keys of src maps, normalized
+// too, are looked up, so the result uses the normalized keys. fn is called with keys
+// of any comparable type, not only strings, and must return a value assignable to
+// the key type; it may return the key unchanged. Values of keys of the same map
+// normalized to the same key are merged into each other in an unspecified order.
+func WithKeyTransformer(fn func(reflect.Value) reflect.Value) Option {
+	return option(func(c *Config) { c.keyTransformer = fn })
+}
+
+// Actions of a TraceEvent.
+const (
+	TraceRecurse   = "recurse"   // the value is merged element by element or field by field
+	TraceOverwrite = "overwrite" // dst is set to a value
+	TraceSkip      = "skip"      // dst is left alone
+	TraceConvert   = "convert"   // src is converted to the type of dst
+	TraceTransform = "transform" // a transformer merges the value
+)
+
+// TraceEvent describes a decision made by DeepMerge about the value at Path.
+type TraceEvent struct {
+	Path             string
+	Action           string // one of the Trace constants
+	DstType, SrcType reflect.Type
+}
+
+// parallelMapThreshold is the number of keys above which WithParallelism
+// merges the values of a top-level map in parallel.
+const parallelMapThreshold = 1024
+
+// WithParallelism make DeepMerge merge the values of a top-level map with more than
+// a thousand keys in n goroutines, while only the calling goroutine writes to dst.
+// The values of different keys must not share memory, in dst or in src, and the funcs
+// passed in other options, such as transformers, must be safe for concurrent use.
+// Options that observe the merge as it goes, such as WithTracer, WithHooks and
+// WithDryRun, make the merge sequential.
+func WithParallelism(n int) Option {
+	return option(func(c *Config) { c.parallelism = n })
+}
+
+// mergeMapInParallel reports whether a top-level map with n keys is merged
+// in parallel, see WithParallelism.
+func (c *Config) mergeMapInParallel(n int) bool {
+	return c.parallelism > 1 && n > parallelMapThreshold &&
+		c.tracer == nil && c.beforeHook == nil && c.afterHook == nil &&
+		c.changes == nil && c.mapWriteHook == nil && c.recoverPath == nil && c.keyTransformer == nil &&
+		c.onceSet == nil
+}
+
+// WithTracer make DeepMerge call fn with every decision it makes, e.g. to find out
+// why a nested field was not merged, without building with the debug tag.
+func WithTracer(fn func(event TraceEvent)) Option {
+	return option(func(c *Config) { c.tracer = fn })
+}
+
+// WithHooks make merge call before and after, if not nil, around merging each value,
+// such as a struct field, a slice element or a map value, with its path. before sees
+// dst as it was before merging and after sees the result. Hooks only observe:
+// they must not modify dst or src.
+func WithHooks(before, after func(path string, dst, src reflect.Value)) Option {
+	return option(func(c *Config) { c.beforeHook, c.afterHook = before, after })
+}
+
+// Allocator creates the pointers, slices and maps that merge allocates in dst,
+// e.g. from an arena or a pool. Each method must return a value like the
+// reflect function of the same name.
+type Allocator interface {
+	New(typ reflect.Type) reflect.Value
+	MakeSlice(typ reflect.Type, len, cap int) reflect.Value
+	MakeMapWithSize(typ reflect.Type, n int) reflect.Value
+}
+
+// WithAllocator make merge allocate new pointers, slices and maps in dst with a
+// instead of the reflect package.
+func WithAllocator(a Allocator) Option {
+	return option(func(c *Config) { c.allocator = a })
+}
+
+// new is like reflect.New but uses the allocator, if any.
+func (c *Config) new(typ reflect.Type) reflect.Value {
+	if c.allocator == nil {
+		return reflect.New(typ)
+	}
+	return c.allocator.New(typ)
+}
+
+// makeSlice is like reflect.MakeSlice but uses the allocator, if any.
+func (c *Config) makeSlice(typ reflect.Type, len, cap int) reflect.Value {
+	if c.allocator == nil {
+		return reflect.MakeSlice(typ, len, cap)
+	}
+	return c.allocator.MakeSlice(typ, len, cap)
+}
+
+// makeMap is like reflect.MakeMapWithSize but uses the allocator, if any.
+func (c *Config) makeMap(typ reflect.Type, n int) reflect.Value {
+	if c.allocator == nil {
+		return reflect.MakeMapWithSize(typ, n)
+	}
+	return c.allocator.MakeMapWithSize(typ, n)
+}
+
+// trace reports the action about the values at path to the tracer, if any.
+func (c *Config) trace(path, action string, dst, src reflect.Value) {
+	if c.tracer == nil {
+		return
+	}
+
+	e := TraceEvent{Path: path, Action: action}
+	if dst.IsValid() {
+		e.DstType = dst.Type()
+	}
+	if src.IsValid() {
+		e.SrcType = src.Type()
+	}
+	c.tracer(e)
+}
+
 // WithTransformer adds transformer to merge, allowing to customize the merging of some types.
 // The transformer f must be a function "func(dst *T, src T) error"
 func WithTransformer(f any) Option {
 	return option(func(c *Config) {
-		if c.transformers == nil {
-			c.transformers = make(map[reflect.Type]reflect.Value)
+		vf := reflect.ValueOf(f)
+		typeOfF := vf.Type()
+		if reflect.Func != typeOfF.Kind() ||
+			typeOfF.NumIn() != 2 || reflect.Pointer != typeOfF.In(0).Kind() ||
+			typeOfF.In(0).Elem() != typeOfF.In(1) ||
+			typeOfF.NumOut() != 1 || reflect.TypeOf(new(error)).Elem() != typeOfF.Out(0) {
+			panic(`f must be a function "func(dst *T, src T) error"`)
 		}
+		c.addTransformer(typeOfF.In(0).Elem(), vf)
+	})
+}
+
+// WithTransformerFor adds f as the transformer for each of types, like WithTransformer
+// for a function "func(dst *T, src T) error" registered for several types at once.
+// f is called with the address of the dst value and the src value.
+func WithTransformerFor(types []reflect.Type, f func(dst, src reflect.Value) error) Option {
+	return option(func(c *Config) {
+		if f == nil {
+			panic("WithTransformerFor called with nil f")
+		}
+
+		errorType := reflect.TypeOf(new(error)).Elem()
+		for _, typ := range types {
+			if typ == nil {
+				panic("WithTransformerFor called with nil type")
+			}
+			fnType := reflect.FuncOf([]reflect.Type{reflect.PointerTo(typ), typ}, []reflect.Type{errorType}, false)
+			c.addTransformer(typ, reflect.MakeFunc(fnType, func(in []reflect.Value) []reflect.Value {
+				err := reflect.New(errorType).Elem()
+				if e := f(in[0], in[1]); e != nil {
+					err.Set(reflect.ValueOf(e))
+				}
+				return []reflect.Value{err}
+			}))
+		}
+	})
+}
 
+// WithNamedTransformer adds transformer f under name. Struct fields tagged
+// `merge:"transform=name"` are merged by f instead of by their type, so that
+// fields of the same type can be merged differently.
+// The transformer f must be a function "func(dst *T, src T) error"
+// where T is the type of the tagged fields.
+func WithNamedTransformer(name string, f any) Option {
+	return option(func(c *Config) {
 		vf := reflect.ValueOf(f)
 		typeOfF := vf.Type()
 		if reflect.Func != typeOfF.Kind() ||
@@ -82,10 +1188,559 @@ func WithTransformer(f any) Option {
 			typeOfF.NumOut() != 1 || reflect.TypeOf(new(error)).Elem() != typeOfF.Out(0) {
 			panic(`f must be a function "func(dst *T, src T) error"`)
 		}
-		typ := vf.Type().In(0).Elem()
-		if _, dup := c.transformers[typ]; dup {
-			panic("WithTransformer called twice for type " + typ.String())
+
+		if c.namedTransformers == nil {
+			c.namedTransformers = make(map[string]reflect.Value)
 		}
-		c.transformers[typ] = vf
+		if _, dup := c.namedTransformers[name]; dup {
+			panic("WithNamedTransformer called twice for name " + name)
+		}
+		c.namedTransformers[name] = vf
 	})
 }
+
+// WithPartialTransformer make merge call f for every pair of dst and src values,
+// after the transformers registered by type. If f returns handled true, the value
+// is considered merged; otherwise merge continues as usual, e.g. after f has
+// normalized dst. A non-nil error stops the merge either way.
+func WithPartialTransformer(f func(dst, src reflect.Value) (handled bool, err error)) Option {
+	return option(func(c *Config) { c.partialTransformer = f })
+}
+
+// transformPartial calls the partial transformer, if any.
+// It reports whether the value is handled or an error occurred.
+func (c *Config) transformPartial(dst, src reflect.Value) (bool, error) {
+	if c.partialTransformer == nil {
+		return false, nil
+	}
+	handled, err := c.partialTransformer(dst, src)
+	return handled || err != nil, err
+}
+
+// WithElementTransformer adds a transformer for the elements of slices of type sliceType,
+// allowing to customize the merging of the elements without affecting other values
+// of the element type. The transformer f must be a function "func(dst *E, src E) error"
+// where E is the element type of sliceType. It is called for each pair of dst and src
+// elements merged, not for elements appended or prepended by WithAppendSlice or
+// WithPrependSlice.
+func WithElementTransformer(sliceType reflect.Type, f any) Option {
+	return option(func(c *Config) {
+		if sliceType == nil || reflect.Slice != sliceType.Kind() {
+			panic("WithElementTransformer called with non-slice type")
+		}
+
+		vf := reflect.ValueOf(f)
+		typeOfF := vf.Type()
+		if reflect.Func != typeOfF.Kind() ||
+			typeOfF.NumIn() != 2 || reflect.PointerTo(sliceType.Elem()) != typeOfF.In(0) ||
+			sliceType.Elem() != typeOfF.In(1) ||
+			typeOfF.NumOut() != 1 || reflect.TypeOf(new(error)).Elem() != typeOfF.Out(0) {
+			panic(`f must be a function "func(dst *E, src E) error"`)
+		}
+
+		if c.elemTransformers == nil {
+			c.elemTransformers = make(map[reflect.Type]reflect.Value)
+		}
+		if _, dup := c.elemTransformers[sliceType]; dup {
+			panic("WithElementTransformer called twice for type " + sliceType.String())
+		}
+		c.elemTransformers[sliceType] = vf
+	})
+}
+
+// WithTimeTransformer adds a transformer for time.Time values, which have no exported
+// fields to merge. A zero dst time (as reported by time.Time.IsZero) is set to src;
+// if overwrite is true, a non-zero src time also replaces a non-zero dst time.
+// A zero src time never clobbers dst.
+func WithTimeTransformer(overwrite bool) Option {
+	return WithTransformer(func(dst *time.Time, src time.Time) error {
+		if !src.IsZero() && (dst.IsZero() || overwrite) {
+			*dst = src
+		}
+		return nil
+	})
+}
+
+// WithBigNumberSupport adds transformers for *big.Int, *big.Float and *big.Rat values,
+// which have no exported fields to merge. A nil or zero dst number is set to a copy of
+// a non-zero src number with its Set method, so that dst never aliases src.
+// WithOverwrite and WithOverwriteWithEmptyValue apply as for other numbers;
+// a nil src number leaves dst alone.
+func WithBigNumberSupport() Option {
+	return option(func(c *Config) {
+		c.addTransformer(reflect.TypeOf((*big.Int)(nil)), reflect.ValueOf(bigNumberTransformer[big.Int]))
+		c.addTransformer(reflect.TypeOf((*big.Float)(nil)), reflect.ValueOf(bigNumberTransformer[big.Float]))
+		c.addTransformer(reflect.TypeOf((*big.Rat)(nil)), reflect.ValueOf(bigNumberTransformer[big.Rat]))
+	})
+}
+
+// bigNumberTransformer is the transformer of WithBigNumberSupport for *T.
+// It follows the overwrite settings of c, the Config in effect at the merged value.
+func bigNumberTransformer[T any, P interface {
+	*T
+	Set(P) P
+	Sign() int
+}](c *Config, _ string, dst *P, src P) error {
+	if src == nil || (src.Sign() == 0 && !c.overwriteWithEmptyValue) {
+		return nil
+	}
+	if *dst == nil {
+		*dst = new(T)
+	} else if (*dst).Sign() != 0 && !c.overwrite {
+		return nil
+	}
+	(*dst).Set(src)
+	return nil
+}
+
+// WithEnumTransformer adds a transformer for the enum type T that only merges
+// src values for which valid returns true. A zero src value is unset and leaves dst
+// alone. A valid non-zero src value sets a zero dst, and overwrites a non-zero one
+// as WithOverwrite or WithOverwriteIf allow; for an invalid one merge returns
+// an error, keeping dst.
+func WithEnumTransformer[T ~int](valid func(T) bool) Option {
+	return option(func(c *Config) {
+		// c is the Config in effect at path, which WithOptionsAt may change.
+		c.addTransformer(reflect.TypeOf((*T)(nil)).Elem(), reflect.ValueOf(func(c *Config, path string, dst *T, src T) error {
+			if src == 0 {
+				return nil
+			}
+			if !valid(src) {
+				return fmt.Errorf("%q: invalid %T value %d", path, src, src)
+			}
+			if c.shouldSet(reflect.ValueOf(*dst), reflect.ValueOf(src)) {
+				*dst = src
+			}
+			return nil
+		}))
+	})
+}
+
+// WithPathTransformer is like WithTransformer but the transformer also receives
+// the path of the value being merged, e.g. ".Metadata.Created".
+// The transformer f must be a function "func(path string, dst *T, src T) error"
+func WithPathTransformer(f any) Option {
+	return option(func(c *Config) {
+		vf := reflect.ValueOf(f)
+		typeOfF := vf.Type()
+		if reflect.Func != typeOfF.Kind() ||
+			typeOfF.NumIn() != 3 || reflect.String != typeOfF.In(0).Kind() ||
+			reflect.Pointer != typeOfF.In(1).Kind() ||
+			typeOfF.In(1).Elem() != typeOfF.In(2) ||
+			typeOfF.NumOut() != 1 || reflect.TypeOf(new(error)).Elem() != typeOfF.Out(0) {
+			panic(`f must be a function "func(path string, dst *T, src T) error"`)
+		}
+		c.addTransformer(typeOfF.In(1).Elem(), vf)
+	})
+}
+
+// WithPostProcess adds fn to be called with every value of type typ once it has
+// been fully merged, e.g. to recompute derived fields or validate invariants.
+// Unlike a transformer, fn does not replace the merge.
+func WithPostProcess(typ reflect.Type, fn func(v reflect.Value) error) Option {
+	return option(func(c *Config) {
+		if c.postProcessors == nil {
+			c.postProcessors = make(map[reflect.Type]func(reflect.Value) error)
+		}
+		if _, dup := c.postProcessors[typ]; dup {
+			panic("WithPostProcess called twice for type " + typ.String())
+		}
+		c.postProcessors[typ] = fn
+	})
+}
+
+// WithEnumStringMap make DeepMap map string src values into dst values of the
+// integer type typ using names; an unknown name is an error.
+func WithEnumStringMap(typ reflect.Type, names map[string]int64) Option {
+	return option(func(c *Config) {
+		switch typ.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		default:
+			panic("WithEnumStringMap called with non-integer type " + typ.String())
+		}
+
+		if c.enums == nil {
+			c.enums = make(map[reflect.Type]map[string]int64)
+		}
+		if _, dup := c.enums[typ]; dup {
+			panic("WithEnumStringMap called twice for type " + typ.String())
+		}
+		c.enums[typ] = names
+	})
+}
+
+func (c *Config) addTransformer(typ reflect.Type, fn reflect.Value) {
+	if c.transformers == nil {
+		c.transformers = make(map[reflect.Type]reflect.Value)
+	}
+	if _, dup := c.transformers[typ]; dup {
+		panic("WithTransformer called twice for type " + typ.String())
+	}
+	c.transformers[typ] = fn
+}
+
+// transform calls the transformer registered for dst's type, if any.
+// It reports whether a transformer was found.
+func (c *Config) transform(path string, dst, src reflect.Value) (bool, error) {
+	fn := c.transformers[dst.Type()]
+	if !fn.IsValid() {
+		return false, nil
+	}
+	return true, c.callTransformer(fn, path, dst, src)
+}
+
+// transformPointee calls the transformer registered for the type that the pointers
+// dst and src point to, if any, so that WithoutDereference does not bypass it.
+// A nil dst is set to a new value first. It reports whether a transformer was found.
+func (c *Config) transformPointee(path string, dst, src reflect.Value) (bool, error) {
+	fn := c.transformers[dst.Type().Elem()]
+	if !fn.IsValid() || dst.Type() != src.Type() || src.IsNil() {
+		return false, nil
+	}
+
+	p := dst
+	if dst.IsNil() {
+		p = c.new(dst.Type().Elem()).Convert(dst.Type())
+	}
+	if err := c.callTransformer(fn, "(*"+path+")", p.Elem(), src.Elem()); err != nil {
+		return true, err
+	}
+	if dst.IsNil() {
+		c.set(path, dst, p)
+	}
+	return true, nil
+}
+
+// transformElement calls the element transformer registered for slices of type
+// sliceType, if any, on their elements dst and src.
+// It reports whether a transformer was found.
+func (c *Config) transformElement(path string, sliceType reflect.Type, dst, src reflect.Value) (bool, error) {
+	fn := c.elemTransformers[sliceType]
+	if !fn.IsValid() || src.Type() != dst.Type() {
+		return false, nil
+	}
+	return true, c.callTransformer(fn, path, dst, src)
+}
+
+// transformField calls the named transformer that the struct field f is tagged with, if any.
+// It reports whether the field is tagged.
+func (c *Config) transformField(path string, f *field, dst, src reflect.Value) (bool, error) {
+	if f.transform == "" {
+		return false, nil
+	}
+
+	fn := c.namedTransformers[f.transform]
+	if !fn.IsValid() {
+		return true, fmt.Errorf("%q: unknown transformer %q", path, f.transform)
+	}
+	if fn.Type().In(1) != dst.Type() || !src.Type().AssignableTo(dst.Type()) {
+		return true, fmt.Errorf("%q: transformer %q cannot merge %s", path, f.transform, dst.Type())
+	}
+	return true, c.callTransformer(fn, path, dst, src)
+}
+
+// callTransformer calls the transformer fn with dst's address and src,
+// preceded by path if fn takes it. The transformers of this package also take c,
+// the Config in effect at path, first.
+func (c *Config) callTransformer(fn reflect.Value, path string, dst, src reflect.Value) error {
+	var in []reflect.Value
+	switch fn.Type().NumIn() {
+	case 4:
+		in = []reflect.Value{reflect.ValueOf(c), reflect.ValueOf(path), dst.Addr(), src}
+	case 3:
+		in = []reflect.Value{reflect.ValueOf(path).Convert(fn.Type().In(0)), dst.Addr(), src}
+	default:
+		in = []reflect.Value{dst.Addr(), src}
+	}
+	err, _ := fn.Call(in)[0].Interface().(error)
+	return err
+}
+
+// appendMapSlice returns the dst map value old with the src map value v appended
+// if both are slices of the same type, possibly held in interfaces, and
+// c.appendMapSlices is set. It reports whether v was appended.
+func (c *Config) appendMapSlice(old, v reflect.Value) (reflect.Value, bool) {
+	if !c.appendMapSlices || !old.IsValid() {
+		return reflect.Value{}, false
+	}
+	if reflect.Interface == old.Kind() {
+		old = old.Elem()
+	}
+	if reflect.Interface == v.Kind() {
+		v = v.Elem()
+	}
+	if !old.IsValid() || !v.IsValid() || reflect.Slice != old.Kind() || old.Type() != v.Type() {
+		return reflect.Value{}, false
+	}
+	return c.appendSlice(old, v), true
+}
+
+// setMapIndex sets m[k] = v. If old is the value previously stored at m[k] and
+// it equals v, the write is skipped when c.skipUnchangedMapWrites is set.
+func (c *Config) setMapIndex(m, k, v, old reflect.Value) {
+	if c.skipUnchangedMapWrites && old.IsValid() &&
+		v.Comparable() && old.Comparable() && v.Equal(old) {
+		return
+	}
+
+	m.SetMapIndex(k, v)
+	if c.mapWriteHook != nil {
+		c.mapWriteHook(m, k, v)
+	}
+}
+
+// filterField reports whether the struct field at path should be merged.
+// For deepValueMap, fn is passed the path in the syntax of deepValueMerge,
+// e.g. ".Inner.A" rather than "[Inner][A]", so one filter serves both.
+func (c *Config) filterField(path string, field reflect.StructField) bool {
+	if c.mapFieldPaths != nil {
+		c.mapFieldPaths[logicalPath(path)] = true
+		path = c.mergePathOf(path)
+	}
+	return c.fieldFilter == nil || c.fieldFilter(path, field)
+}
+
+// mergePathOf returns the deepValueMap path in the syntax of deepValueMerge,
+// selecting the struct fields recorded in c.mapFieldPaths with a dot.
+func (c *Config) mergePathOf(path string) string {
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		j := strings.IndexByte(path[i:], ']')
+		if '[' != path[i] || j < 0 {
+			b.WriteByte(path[i])
+			continue
+		}
+		if c.mapFieldPaths[logicalPath(path[:i+j+1])] {
+			b.WriteString("." + path[i+1:i+j])
+		} else {
+			b.WriteString(path[i : i+j+1])
+		}
+		i += j
+	}
+	return b.String()
+}
+
+// validate reports an error if the options applied to c contradict each other.
+func (c *Config) validate() error {
+	if name := c.overwritingOption(); c.defaultsOnly && name != "" {
+		return fmt.Errorf("%s can not be used with WithDefaultsOnly", name)
+	}
+	if c.typeCheck && !c.overwrite {
+		return errors.New("WithTypeCheck must be used with WithOverwrite")
+	}
+	if c.floatTolerance < 0 || math.IsNaN(c.floatTolerance) {
+		return fmt.Errorf("WithFloatTolerance: invalid tolerance %g", c.floatTolerance)
+	}
+	if c.prependSlice && SliceMergeByIndex != c.sliceStrategy {
+		return errors.New("WithPrependSlice can not be used with WithAppendSlice or another slice strategy")
+	}
+	if c.preferLongerSlice && (SliceMergeByIndex != c.sliceStrategy || c.prependSlice) {
+		return errors.New("WithPreferLongerSlice can not be used with WithPrependSlice or a slice strategy")
+	}
+	if c.unexportedFields && !canExposeUnexported {
+		return errors.New("WithUnexportedFields is not available in builds with the nounsafe tag")
+	}
+	return nil
+}
+
+// isZero reports whether v is empty, as defined by WithEmptyFunc
+// or reflect.Value.IsZero by default.
+func (c *Config) isZero(v reflect.Value) bool {
+	if c.emptyFunc != nil {
+		return c.emptyFunc(v)
+	}
+	return v.IsZero()
+}
+
+// roundFloat rounds f to the decimal places given by WithFloatRound, if any.
+func (c *Config) roundFloat(f float64) float64 {
+	if !c.roundFloats || math.IsInf(f, 0) || math.IsNaN(f) {
+		return f
+	}
+	p := math.Pow10(c.floatPlaces)
+	return math.Round(f*p) / p
+}
+
+// withinFloatTolerance reports whether f is within the WithFloatTolerance of the float dst.
+func (c *Config) withinFloatTolerance(dst reflect.Value, f float64) bool {
+	return c.floatTolerance > 0 && math.Abs(dst.Float()-f) <= c.floatTolerance
+}
+
+// checkIdempotent merges src into a deep copy of dst using merge and reports
+// an error if the copy then differs from dst as Equal compares them.
+// The second merge neither records changes nor calls tracers and hooks.
+func (c *Config) checkIdempotent(dst, src reflect.Value,
+	merge func(string, reflect.Value, reflect.Value, map[visit]string, *Config) error) error {
+	cc := c.clone()
+	cc.dryRun, cc.changes, cc.recoverPath = false, nil, nil
+	cc.tracer, cc.beforeHook, cc.afterHook = nil, nil, nil
+
+	cp := reflect.New(dst.Type()).Elem()
+	cp.Set(c.copyOf(dst))
+	if err := merge("", cp, src, make(map[visit]string), cc); err != nil {
+		return err
+	}
+	d := differ{first: true}
+	deepValueDiff("", dst, cp, make(map[diffVisit]bool), &d, c)
+	if len(d.changes) > 0 {
+		return errors.New("merge is not idempotent: merging src again changes dst")
+	}
+	return nil
+}
+
+// shouldSet reports whether the value dst, merged as a whole, should be set to src.
+func (c *Config) shouldSet(dst, src reflect.Value) bool {
+	if c.isZero(src) && !c.overwriteWithEmptyValue {
+		return false
+	}
+	if c.isZero(dst) {
+		return true
+	}
+	if c.overwriteIf != nil {
+		return c.overwriteIf(dst, src)
+	}
+	return c.overwrite
+}
+
+// set sets dst to v, recording the change if requested.
+func (c *Config) set(path string, dst, v reflect.Value) {
+	if !c.assignOnce(path) {
+		c.trace(path, TraceSkip, dst, v)
+		return
+	}
+	c.trace(path, TraceOverwrite, dst, v)
+	if c.changes != nil {
+		*c.changes = append(*c.changes, Change{path, valueInterface(dst), valueInterface(v)})
+	}
+	dst.Set(v)
+}
+
+// resolveMapConflict calls the map conflict func, if any, for the key k present
+// in both the dst map m, with value old, and the src map, with value v.
+// It reports whether the conflict was resolved.
+func (c *Config) resolveMapConflict(path string, m, k, old, v reflect.Value) (bool, error) {
+	if c.mapConflict == nil || !old.IsValid() {
+		return false, nil
+	}
+
+	r, err := c.mapConflict(k, old, v)
+	if err != nil {
+		return true, fmt.Errorf("%q: %w", path, err)
+	}
+	if !r.IsValid() {
+		return true, nil
+	}
+	if !r.Type().AssignableTo(m.Type().Elem()) {
+		return true, fmt.Errorf("%q: cannot use %s as %s map value", path, r.Type(), m.Type().Elem())
+	}
+
+	c.setMapValue(path, m, k, r, old)
+	return true, nil
+}
+
+// setMapValue sets m[k] = v, where v replaces the value old at path as a whole, such as
+// a resolved conflict or appended slices. Like set, it honors WithImmutableOnceSet
+// and traces and records the change.
+func (c *Config) setMapValue(path string, m, k, v, old reflect.Value) {
+	if !c.assignOnce(path) {
+		c.trace(path, TraceSkip, old, v)
+		return
+	}
+	c.trace(path, TraceOverwrite, old, v)
+	if c.changes != nil {
+		*c.changes = append(*c.changes, Change{path, valueInterface(old), valueInterface(v)})
+	}
+	c.setMapIndex(m, k, v, old)
+}
+
+// transformKey returns the key k of the map m normalized by WithKeyTransformer, if any.
+func (c *Config) transformKey(path string, m, k reflect.Value) (reflect.Value, error) {
+	if c.keyTransformer == nil {
+		return k, nil
+	}
+
+	kt := m.Type().Key()
+	nk := c.keyTransformer(k)
+	if !nk.IsValid() || !nk.Type().AssignableTo(kt) {
+		return k, fmt.Errorf("%q: key transformer returned %v for key %v, want a %s", path, nk, k, kt)
+	}
+	return nk.Convert(kt), nil
+}
+
+// normalizeMapKeys replaces the keys of the map m with their WithKeyTransformer form,
+// merging with merge the values of keys normalized to the same key.
+func (c *Config) normalizeMapKeys(path string, m reflect.Value, visited map[visit]string,
+	merge func(string, reflect.Value, reflect.Value, map[visit]string, *Config) error) error {
+	if c.keyTransformer == nil || m.IsNil() {
+		return nil
+	}
+
+	for _, k := range m.MapKeys() {
+		nk, err := c.transformKey(path, m, k)
+		if err != nil {
+			return err
+		}
+		if nk.Equal(k) {
+			continue
+		}
+
+		v := m.MapIndex(k)
+		if old := m.MapIndex(nk); old.IsValid() {
+			nv := c.new(m.Type().Elem()).Elem()
+			nv.Set(old)
+			if err := merge(fmt.Sprintf("%s[%v]", path, nk), nv, v, visited, c); err != nil {
+				return err
+			}
+			v = nv
+		}
+		m.SetMapIndex(k, reflect.Value{})
+		c.setMapIndex(m, nk, v, reflect.Value{})
+	}
+	return nil
+}
+
+// srcHasKey reports whether the src map has the dst key k, normalized by WithKeyTransformer.
+func (c *Config) srcHasKey(src, k reflect.Value) bool {
+	if c.keyTransformer == nil {
+		return src.MapIndex(k).IsValid()
+	}
+	for it := src.MapRange(); it.Next(); {
+		if nk, err := c.transformKey("", src, it.Key()); err == nil && nk.Equal(k) {
+			return true
+		}
+	}
+	return false
+}
+
+// deleteMapIndex deletes the key k from the map m, recording the change if requested.
+func (c *Config) deleteMapIndex(path string, m, k reflect.Value) {
+	if !c.assignOnce(path) {
+		return
+	}
+	if c.changes != nil {
+		*c.changes = append(*c.changes, Change{path, valueInterface(m.MapIndex(k)), nil})
+	}
+	m.SetMapIndex(k, reflect.Value{})
+}
+
+func valueInterface(v reflect.Value) any {
+	if !v.IsValid() || !v.CanInterface() {
+		return nil
+	}
+	return v.Interface()
+}
+
+// reducer returns the Reducer for the numeric value of type typ at path, or 0 if none.
+func (c *Config) reducer(path string, typ reflect.Type) Reducer {
+	if r, ok := c.pathReducers[path]; ok {
+		return r
+	}
+	if r, ok := c.typeReducers[typ]; ok {
+		return r
+	}
+	if c.numericAdd {
+		return ReduceSum
+	}
+	return 0
+}
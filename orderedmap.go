@@ -0,0 +1,115 @@
+package merge
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// OrderedMap is implemented by map types that are not Go maps but keep their keys
+// in order, such as those used for YAML round-trips. DeepMerge and DeepMap treat
+// a value whose pointer implements OrderedMap like a map of the same type:
+// they iterate the keys of src in order, merging the value of every key into
+// the value of dst for the same key, and Set the keys of src missing in dst
+// after the existing keys.
+type OrderedMap[K comparable, V any] interface {
+	Keys() []K
+	Get(K) (V, bool)
+	Set(K, V)
+}
+
+// orderedMapType records the key and value types of an OrderedMap pointer type.
+type orderedMapType struct {
+	ok      bool
+	key, el reflect.Type
+}
+
+var orderedMapCache sync.Map // map[reflect.Type]orderedMapType
+
+// orderedMapOf reports whether the pointer type *t implements OrderedMap,
+// and for which key and value types.
+func orderedMapOf(t reflect.Type) orderedMapType {
+	if m, ok := orderedMapCache.Load(t); ok {
+		return m.(orderedMapType)
+	}
+	m, _ := orderedMapCache.LoadOrStore(t, typeOrderedMap(reflect.PointerTo(t)))
+	return m.(orderedMapType)
+}
+
+func typeOrderedMap(pt reflect.Type) orderedMapType {
+	keys, ok1 := pt.MethodByName("Keys")
+	get, ok2 := pt.MethodByName("Get")
+	set, ok3 := pt.MethodByName("Set")
+	if !ok1 || !ok2 || !ok3 {
+		return orderedMapType{}
+	}
+
+	// The method types include the receiver.
+	kt, gt, st := keys.Type, get.Type, set.Type
+	if kt.NumIn() != 1 || kt.NumOut() != 1 || reflect.Slice != kt.Out(0).Kind() {
+		return orderedMapType{}
+	}
+	key := kt.Out(0).Elem()
+	if !key.Comparable() || gt.NumIn() != 2 || gt.In(1) != key ||
+		gt.NumOut() != 2 || reflect.Bool != gt.Out(1).Kind() {
+		return orderedMapType{}
+	}
+	el := gt.Out(0)
+	if st.NumIn() != 3 || st.In(1) != key || st.In(2) != el || st.NumOut() != 0 {
+		return orderedMapType{}
+	}
+	return orderedMapType{true, key, el}
+}
+
+// isOrderedMap reports whether dst and src are ordered maps to be merged
+// by mergeOrderedMap.
+func isOrderedMap(dst, src reflect.Value) bool {
+	switch dst.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		return false
+	}
+	return dst.CanAddr() && dst.Type() == src.Type() && orderedMapOf(dst.Type()).ok
+}
+
+// mergeOrderedMap merges the ordered map src into dst with merge,
+// deepValueMerge or deepValueMap.
+func (c *Config) mergeOrderedMap(path string, dst, src reflect.Value, visited map[visit]string,
+	merge func(string, reflect.Value, reflect.Value, map[visit]string, *Config) error) error {
+	el := orderedMapOf(dst.Type()).el
+
+	// The methods may have pointer receivers.
+	if !src.CanAddr() {
+		p := reflect.New(src.Type())
+		p.Elem().Set(src)
+		src = p.Elem()
+	}
+	dm, sm := dst.Addr(), src.Addr()
+
+	// A dry run merges into a copy of dst, which deepCopy copies shallowly.
+	if c.dryRun {
+		cp := reflect.New(dst.Type())
+		keys := dm.MethodByName("Keys").Call(nil)[0]
+		for i := 0; i < keys.Len(); i++ {
+			k := keys.Index(i)
+			v := dm.MethodByName("Get").Call([]reflect.Value{k})[0]
+			cp.MethodByName("Set").Call([]reflect.Value{k, c.copyOf(v)})
+		}
+		dst.Set(cp.Elem())
+	}
+
+	keys := sm.MethodByName("Keys").Call(nil)[0]
+	for i := 0; i < keys.Len(); i++ {
+		k := keys.Index(i)
+		sv := sm.MethodByName("Get").Call([]reflect.Value{k})[0]
+
+		v := reflect.New(el).Elem()
+		if r := dm.MethodByName("Get").Call([]reflect.Value{k}); r[1].Bool() {
+			v.Set(r[0])
+		}
+		if err := merge(fmt.Sprintf("%s[%v]", path, k), v, sv, visited, c); err != nil {
+			return err
+		}
+		dm.MethodByName("Set").Call([]reflect.Value{k, v})
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package merge
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// MergeTyped is like DeepMerge but requires dst and src to share type T,
+// so mismatched arguments are rejected at compile time.
+func MergeTyped[T any](dst *T, src T, opts ...Option) error {
+	if dst == nil {
+		return errors.New("dst is nil")
+	}
+	return MergeValue(reflect.ValueOf(dst).Elem(), reflect.ValueOf(&src).Elem(), opts...)
+}
+
+// MapTyped is like DeepMap but requires dst and src to share type T,
+// so mismatched arguments are rejected at compile time.
+func MapTyped[T any](dst *T, src T, opts ...Option) error {
+	if dst == nil {
+		return errors.New("dst is nil")
+	}
+	return MapValue(reflect.ValueOf(dst).Elem(), reflect.ValueOf(&src).Elem(), opts...)
+}
+
+// MergeInto merges into dst only the fields of src named by fields, leaving the
+// other fields of dst untouched, as for a PATCH request. Each selector returns the
+// address of a field of the struct it is passed, possibly a nested one, as in
+//
+//	MergeInto(&cfg, patch, func(c *Config) any { return &c.Server.Port })
+//
+// Selected fields are merged like DeepMerge with WithOverwriteWithEmptyValue,
+// so an empty src field, such as a zero port, is set in dst too.
+func MergeInto[T any](dst *T, src T, fields ...func(*T) any) error {
+	if dst == nil {
+		return errors.New("dst is nil")
+	}
+
+	// Resolve every selector before merging, so that a bad one leaves dst untouched.
+	vdst, vsrc := reflect.ValueOf(dst).Elem(), reflect.ValueOf(&src).Elem()
+	indexes := make([][]int, len(fields))
+	for i, sel := range fields {
+		p := reflect.ValueOf(sel(dst))
+		if reflect.Pointer != p.Kind() || p.IsNil() {
+			return fmt.Errorf("field selector %d does not return a pointer", i)
+		}
+
+		// The selected field is found by its offset in dst.
+		base, addr := vdst.Addr().Pointer(), p.Pointer()
+		var index []int
+		ok := addr >= base
+		if ok {
+			index, ok = fieldIndexAt(vdst.Type(), addr-base, p.Type().Elem())
+		}
+		if !ok {
+			return fmt.Errorf("field selector %d does not return the address of a field of dst", i)
+		}
+		indexes[i] = index
+	}
+
+	for _, index := range indexes {
+		df, sf := vdst, vsrc
+		if len(index) > 0 {
+			df, sf = vdst.FieldByIndex(index), vsrc.FieldByIndex(index)
+		}
+		if err := MergeValue(df, sf, WithOverwriteWithEmptyValue()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fieldIndexAt returns the index sequence of the field of type ft at offset
+// in a value of type t, looking into nested struct fields.
+func fieldIndexAt(t reflect.Type, offset uintptr, ft reflect.Type) ([]int, bool) {
+	if 0 == offset && t == ft {
+		return nil, true
+	}
+	if reflect.Struct != t.Kind() {
+		return nil, false
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if offset < f.Offset || offset >= f.Offset+f.Type.Size() {
+			continue
+		}
+		if index, ok := fieldIndexAt(f.Type, offset-f.Offset, ft); ok {
+			return append([]int{i}, index...), true
+		}
+	}
+	return nil, false
+}
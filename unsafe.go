@@ -0,0 +1,34 @@
+//go:build !nounsafe
+
+package merge
+
+import (
+	"reflect"
+	"unsafe"
+)
+
+// pointer identifies the value a pointer, map or slice refers to.
+type pointer = unsafe.Pointer
+
+func valuePointer(v reflect.Value) pointer {
+	return v.UnsafePointer()
+}
+
+// canExposeUnexported reports whether exposeUnexported is available.
+const canExposeUnexported = true
+
+// exposeUnexported returns the addressable value v, obtained through an unexported
+// struct field, as if it had been obtained through an exported one.
+func exposeUnexported(v reflect.Value) reflect.Value {
+	return reflect.NewAt(v.Type(), unsafe.Pointer(v.UnsafeAddr())).Elem()
+}
+
+// bytesToString returns the string of v, a slice of bytes, without copying it first.
+func bytesToString(v reflect.Value) reflect.Value {
+	return reflect.ValueOf(unsafe.Slice((*uint8)(v.UnsafePointer()), v.Len())).Convert(reflect.TypeOf(""))
+}
+
+// runesToString returns the string of v, a slice of runes.
+func runesToString(v reflect.Value) reflect.Value {
+	return reflect.ValueOf(unsafe.Slice((*int32)(v.UnsafePointer()), v.Len())).Convert(reflect.TypeOf(""))
+}